@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+	"github.com/bnuredini/pathsurfer/internal/fuzzy"
+)
+
+const filterUsage = `usage:
+  psurf filter [--dir <path>] [--paths] [--limit <n>] [--scores | --json] <pattern>`
+
+// errNoMatch is returned by runFilter and runJump when nothing matches so that
+// scripts can tell from the exit code, like with grep.
+var errNoMatch = errors.New("nothing matches")
+
+// filterMatch is how a match is shown by "filter --json". Indexes are the
+// byte offsets of the matched characters in the candidate.
+type filterMatch struct {
+	Candidate string `json:"candidate"`
+	Score     int    `json:"score"`
+	Indexes   []int  `json:"indexes"`
+}
+
+// runFilter ranks candidates against a pattern the same way the navigator does
+// and prints the matches, best first. The candidates are read from stdin, one
+// per line, or are the entries of a directory.
+func runFilter(config *conf.Config, args []string) error {
+	fs := flag.NewFlagSet("filter", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Match the entries of this directory instead of the lines of stdin")
+	paths := fs.Bool("paths", false, "Rank the candidates as paths, like the finder does")
+	limit := fs.Int("limit", 0, "Print at most this many matches (0 means no limit)")
+	printScores := fs.Bool("scores", false, "Print the score of each match before it")
+	printJSON := fs.Bool("json", false, "Print the matches with their scores and indexes as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), filterUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || fs.Arg(0) == "" || (*printScores && *printJSON) {
+		return errors.New(filterUsage)
+	}
+
+	var candidates []string
+	var err error
+	if *dir != "" {
+		candidates, err = listCandidates(*dir, config.ShowHiddenFiles)
+	} else {
+		candidates, err = readCandidates(stdin)
+	}
+	if err != nil {
+		return err
+	}
+
+	var matches []fuzzy.Match
+	if *paths {
+		matches = fuzzy.FindPaths(fs.Arg(0), candidates)
+	} else {
+		matches = fuzzy.Find(fs.Arg(0), candidates)
+	}
+	if *limit > 0 && len(matches) > *limit {
+		matches = matches[:*limit]
+	}
+
+	if err := writeMatches(stdout, matches, *printScores, *printJSON); err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return errNoMatch
+	}
+
+	return nil
+}
+
+// readCandidates returns the non-empty lines of r.
+func readCandidates(r io.Reader) ([]string, error) {
+	result := []string{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSuffix(scanner.Text(), "\r"); line != "" {
+			result = append(result, line)
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+// listCandidates returns the names of the entries in dir, which is what the
+// search in the navigator matches against.
+func listCandidates(dir string, showHidden bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for _, entry := range entries {
+		if showHidden || !strings.HasPrefix(entry.Name(), ".") {
+			result = append(result, entry.Name())
+		}
+	}
+
+	return result, nil
+}
+
+func writeMatches(w io.Writer, matches []fuzzy.Match, printScores, printJSON bool) error {
+	if printJSON {
+		result := make([]filterMatch, 0, len(matches))
+		for _, match := range matches {
+			result = append(result, filterMatch{
+				Candidate: match.CandidateString,
+				Score:     match.Score,
+				Indexes:   match.Indexes,
+			})
+		}
+
+		return json.NewEncoder(w).Encode(result)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, match := range matches {
+		if printScores {
+			fmt.Fprintf(bw, "%d\t", match.Score)
+		}
+		fmt.Fprintln(bw, match.CandidateString)
+	}
+
+	return bw.Flush()
+}
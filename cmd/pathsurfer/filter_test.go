@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+	"github.com/bnuredini/pathsurfer/internal/fuzzy"
+)
+
+const filterInput = "alpha\nbeta\n\ngamma\ndelta\r\nomega\n"
+
+func TestRunFilter(t *testing.T) {
+	matches := fuzzy.Find("a", []string{"alpha", "beta", "gamma", "delta", "omega"})
+	if len(matches) != 5 {
+		t.Fatalf("want every candidate to match, got %v", matches)
+	}
+
+	lines := func(matches []fuzzy.Match, scores bool) string {
+		var sb strings.Builder
+		for _, match := range matches {
+			if scores {
+				fmt.Fprintf(&sb, "%d\t", match.Score)
+			}
+			fmt.Fprintln(&sb, match.CandidateString)
+		}
+		return sb.String()
+	}
+
+	data := []struct {
+		Args []string
+		Want string
+	}{
+		{[]string{"a"}, lines(matches, false)},
+		{[]string{"--limit", "2", "a"}, lines(matches[:2], false)},
+		{[]string{"--limit", "0", "a"}, lines(matches, false)},
+		{[]string{"--limit", "10", "a"}, lines(matches, false)},
+		{[]string{"--scores", "a"}, lines(matches, true)},
+		{[]string{"--scores", "--limit", "1", "a"}, lines(matches[:1], true)},
+	}
+
+	for _, tt := range data {
+		got, err := runCommand(t, runFilter, &conf.Config{}, filterInput, tt.Args...)
+		if err != nil {
+			t.Errorf("args=%q: %v", tt.Args, err)
+		} else if got != tt.Want {
+			t.Errorf("args=%q: want=%q, got=%q", tt.Args, tt.Want, got)
+		}
+	}
+}
+
+func TestRunFilterJSON(t *testing.T) {
+	matches := fuzzy.Find("a", []string{"alpha", "beta", "gamma", "delta", "omega"})
+
+	data := []struct {
+		Args []string
+		Want []fuzzy.Match
+	}{
+		{[]string{"--json", "a"}, matches},
+		{[]string{"--json", "--limit", "2", "a"}, matches[:2]},
+		{[]string{"--json", "xyz"}, nil},
+	}
+
+	for _, tt := range data {
+		out, err := runCommand(t, runFilter, &conf.Config{}, filterInput, tt.Args...)
+		if tt.Want == nil && !errors.Is(err, errNoMatch) {
+			t.Errorf("args=%q: want errNoMatch, got %v", tt.Args, err)
+		} else if tt.Want != nil && err != nil {
+			t.Errorf("args=%q: %v", tt.Args, err)
+		}
+
+		var got []filterMatch
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Errorf("args=%q: want JSON, got %q: %v", tt.Args, out, err)
+			continue
+		}
+
+		want := []filterMatch{}
+		for _, match := range tt.Want {
+			want = append(want, filterMatch{
+				Candidate: match.CandidateString,
+				Score:     match.Score,
+				Indexes:   match.Indexes,
+			})
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("args=%q: want=%+v, got=%+v", tt.Args, want, got)
+		}
+	}
+}
+
+func TestRunFilterDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"notes.txt", ".hidden.txt", "image.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := runCommand(t, runFilter, &conf.Config{}, "", "--dir", dir, "txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "notes.txt\n"; got != want {
+		t.Errorf("want=%q, got=%q", want, got)
+	}
+
+	got, err = runCommand(t, runFilter, &conf.Config{ShowHiddenFiles: true}, "", "--dir", dir, "txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, ".hidden.txt\n") {
+		t.Errorf("want hidden entries to be matched, got %q", got)
+	}
+}
+
+func TestRunFilterErrors(t *testing.T) {
+	data := []struct {
+		Args     []string
+		WantCode int
+	}{
+		{[]string{"--help"}, 0},
+		{[]string{"-h"}, 0},
+		{[]string{"xyz"}, exitNoMatch},
+		{[]string{}, exitError},
+		{[]string{"a", "b"}, exitError},
+		{[]string{"--scores", "--json", "a"}, exitError},
+		{[]string{"--limit", "many", "a"}, exitError},
+	}
+
+	for _, tt := range data {
+		out, err := runCommand(t, runFilter, &conf.Config{}, filterInput, tt.Args...)
+		if got := exitCode(err); got != tt.WantCode {
+			t.Errorf("args=%q: want exit code %v, got %v (%v)", tt.Args, tt.WantCode, got, err)
+		}
+		if out != "" {
+			t.Errorf("args=%q: want nothing printed, got %q", tt.Args, out)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+	"github.com/bnuredini/pathsurfer/internal/frecency"
+)
+
+// runJump prints the best match for the query among the directories stored in
+// the frecency database. Directories that don't exist anymore are dropped from
+// the database along the way.
+func runJump(config *conf.Config, args []string) error {
+	query := strings.TrimSpace(strings.Join(args, " "))
+	if query == "" {
+		return errors.New("jump: missing query")
+	}
+
+	found := ""
+	err := frecency.Update(config.FrecencyFilePath, func(store *frecency.Store) error {
+		now := time.Now()
+
+		for _, entry := range store.Query(query, now) {
+			info, err := os.Stat(entry.Path)
+			if err != nil || !info.IsDir() {
+				store.Remove(entry.Path)
+				continue
+			}
+
+			found = entry.Path
+			store.Visit(found, now)
+			break
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if found == "" {
+		log.Printf("jump: no directory matches %q", query)
+		return errNoMatch
+	}
+
+	fmt.Fprintln(stdout, found)
+
+	return nil
+}
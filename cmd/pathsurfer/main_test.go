@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+)
+
+// runCommand runs a subcommand with input as stdin and returns what it
+// printed.
+func runCommand(t *testing.T, run func(*conf.Config, []string) error, config *conf.Config, input string, args ...string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	oldStdin, oldStdout := stdin, stdout
+	stdin, stdout = strings.NewReader(input), &out
+	t.Cleanup(func() {
+		stdin, stdout = oldStdin, oldStdout
+	})
+
+	err := run(config, args)
+	return out.String(), err
+}
+
+func TestExitCode(t *testing.T) {
+	data := []struct {
+		Err  error
+		Want int
+	}{
+		{nil, 0},
+		{flag.ErrHelp, 0},
+		{errNoMatch, exitNoMatch},
+		{fmt.Errorf("jump: %w", errNoMatch), exitNoMatch},
+		{errors.New("failed"), exitError},
+	}
+
+	for _, tt := range data {
+		if got := exitCode(tt.Err); got != tt.Want {
+			t.Errorf("err=%v: want=%v, got=%v", tt.Err, tt.Want, got)
+		}
+	}
+}
+
+func TestSubcommand(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() {
+		os.Args = oldArgs
+		flag.CommandLine.Parse(nil)
+	})
+
+	data := []struct {
+		Args []string
+		Want string
+	}{
+		{[]string{}, ""},
+		{[]string{"marks", "list"}, "marks"},
+		{[]string{"./marks"}, "./marks"},
+		{[]string{"--", "marks"}, ""},
+		{[]string{"--", "jump"}, ""},
+	}
+
+	for _, tt := range data {
+		os.Args = append([]string{"psurf"}, tt.Args...)
+		if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := subcommand(); got != tt.Want {
+			t.Errorf("args=%q: want=%q, got=%q", tt.Args, tt.Want, got)
+		}
+	}
+}
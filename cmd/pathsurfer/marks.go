@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+	"github.com/bnuredini/pathsurfer/internal/marks"
+)
+
+const marksUsage = `usage:
+  psurf marks list [--json]
+  psurf marks get <key>
+  psurf marks set <key> <path>
+  psurf marks rm <key>
+  psurf marks export
+  psurf marks import [--replace] [file]`
+
+// runMarks reads and changes the marks stored in the mark file without
+// starting the navigator.
+func runMarks(config *conf.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(marksUsage)
+	}
+
+	store := marks.NewStore(config.MarkFilePath)
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "list":
+		return runMarksList(store, args)
+	case "get":
+		return runMarksGet(store, args)
+	case "set":
+		return runMarksSet(store, args)
+	case "rm":
+		return runMarksRemove(store, args)
+	case "export":
+		return runMarksExport(store, args)
+	case "import":
+		return runMarksImport(store, args)
+	}
+
+	return fmt.Errorf("marks: unknown command %q\n%s", command, marksUsage)
+}
+
+// markInfo is how a mark is shown by "marks list --json".
+type markInfo struct {
+	Key    string `json:"key"`
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+}
+
+func runMarksList(store *marks.Store, args []string) error {
+	fs := flag.NewFlagSet("marks list", flag.ContinueOnError)
+	printJSON := fs.Bool("json", false, "Print the marks as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New(marksUsage)
+	}
+
+	storedMarks, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	infos := []markInfo{}
+	for _, key := range slices.Sorted(maps.Keys(storedMarks)) {
+		path := storedMarks[key]
+		info, err := os.Stat(path)
+
+		infos = append(infos, markInfo{
+			Key:    string(key),
+			Path:   path,
+			Exists: err == nil && info.IsDir(),
+		})
+	}
+
+	if *printJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "    ")
+		return enc.Encode(infos)
+	}
+
+	for _, info := range infos {
+		fmt.Fprintf(stdout, "%s\t%s\n", info.Key, info.Path)
+	}
+
+	return nil
+}
+
+func runMarksGet(store *marks.Store, args []string) error {
+	if len(args) != 1 {
+		return errors.New(marksUsage)
+	}
+
+	key, err := marks.ParseKey(args[0])
+	if err != nil {
+		return err
+	}
+
+	storedMarks, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	path, ok := storedMarks[key]
+	if !ok {
+		return fmt.Errorf("%w: %c", marks.ErrNotFound, key)
+	}
+
+	fmt.Fprintln(stdout, path)
+
+	return nil
+}
+
+func runMarksSet(store *marks.Store, args []string) error {
+	if len(args) != 2 {
+		return errors.New(marksUsage)
+	}
+
+	key, err := marks.ParseKey(args[0])
+	if err != nil {
+		return err
+	}
+
+	// The directory doesn't have to exist yet so that marks can be set up
+	// before a machine is fully provisioned.
+	path, err := filepath.Abs(args[1])
+	if err != nil {
+		return err
+	}
+
+	_, err = store.Set(key, path)
+	return err
+}
+
+func runMarksRemove(store *marks.Store, args []string) error {
+	if len(args) != 1 {
+		return errors.New(marksUsage)
+	}
+
+	key, err := marks.ParseKey(args[0])
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(key)
+}
+
+func runMarksExport(store *marks.Store, args []string) error {
+	if len(args) != 0 {
+		return errors.New(marksUsage)
+	}
+
+	storedMarks, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	return marks.EncodeJSON(stdout, storedMarks)
+}
+
+// runMarksImport reads marks in the format written by "marks export" from a
+// file or from stdin. Imported marks overwrite existing marks with the same
+// key. With --replace, every other mark is removed as well.
+func runMarksImport(store *marks.Store, args []string) error {
+	fs := flag.NewFlagSet("marks import", flag.ContinueOnError)
+	replace := fs.Bool("replace", false, "Remove the marks that aren't being imported")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New(marksUsage)
+	}
+
+	var r io.Reader = stdin
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	imported, err := marks.DecodeJSON(r)
+	if err != nil {
+		return err
+	}
+
+	return store.Update(func(storedMarks map[rune]string) error {
+		if *replace {
+			clear(storedMarks)
+		}
+
+		maps.Copy(storedMarks, imported)
+		return nil
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+	"github.com/bnuredini/pathsurfer/internal/marks"
+)
+
+func TestRunMarks(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	importFile := filepath.Join(dir, "import.json")
+	if err := os.WriteFile(importFile, []byte(`{"c": "/from/file"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := map[rune]string{'a': existing, 'b': missing}
+
+	data := []struct {
+		Name      string
+		Args      []string
+		Input     string
+		WantOut   string
+		WantMarks map[rune]string
+		WantErr   error
+	}{
+		{
+			Name:    "list",
+			Args:    []string{"list"},
+			WantOut: "a\t" + existing + "\nb\t" + missing + "\n",
+		},
+		{
+			Name:    "get",
+			Args:    []string{"get", "b"},
+			WantOut: missing + "\n",
+		},
+		{
+			Name:    "get a missing mark",
+			Args:    []string{"get", "z"},
+			WantErr: marks.ErrNotFound,
+		},
+		{
+			Name:      "set",
+			Args:      []string{"set", "c", "/new"},
+			WantMarks: map[rune]string{'a': existing, 'b': missing, 'c': "/new"},
+		},
+		{
+			Name:      "set an existing mark",
+			Args:      []string{"set", "a", "/new"},
+			WantMarks: map[rune]string{'a': "/new", 'b': missing},
+		},
+		{
+			Name:      "rm",
+			Args:      []string{"rm", "a"},
+			WantMarks: map[rune]string{'b': missing},
+		},
+		{
+			Name:    "rm a missing mark",
+			Args:    []string{"rm", "z"},
+			WantErr: marks.ErrNotFound,
+		},
+		{
+			Name:      "import from stdin",
+			Args:      []string{"import"},
+			Input:     `{"a": "/imported", "c": "/other"}`,
+			WantMarks: map[rune]string{'a': "/imported", 'b': missing, 'c': "/other"},
+		},
+		{
+			Name:      "import from a file",
+			Args:      []string{"import", importFile},
+			WantMarks: map[rune]string{'a': existing, 'b': missing, 'c': "/from/file"},
+		},
+		{
+			Name:      "import --replace",
+			Args:      []string{"import", "--replace"},
+			Input:     `{"a": "/imported", "c": "/other"}`,
+			WantMarks: map[rune]string{'a': "/imported", 'c': "/other"},
+		},
+		{
+			Name:      "import --replace with nothing",
+			Args:      []string{"import", "--replace", "-"},
+			Input:     `{}`,
+			WantMarks: map[rune]string{},
+		},
+	}
+
+	for _, tt := range data {
+		t.Run(tt.Name, func(t *testing.T) {
+			config := &conf.Config{MarkFilePath: filepath.Join(t.TempDir(), "pathsurfer.marks")}
+			store := marks.NewStore(config.MarkFilePath)
+			err := store.Update(func(m map[rune]string) error {
+				maps.Copy(m, stored)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := runCommand(t, runMarks, config, tt.Input, tt.Args...)
+			if !errors.Is(err, tt.WantErr) {
+				t.Fatalf("want error %v, got %v", tt.WantErr, err)
+			}
+			if out != tt.WantOut {
+				t.Errorf("want output %q, got %q", tt.WantOut, out)
+			}
+
+			wantMarks := tt.WantMarks
+			if wantMarks == nil {
+				wantMarks = stored
+			}
+
+			got, err := store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, wantMarks) {
+				t.Errorf("want marks %v, got %v", wantMarks, got)
+			}
+		})
+	}
+}
+
+func TestRunMarksJSON(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	config := &conf.Config{MarkFilePath: filepath.Join(dir, "pathsurfer.marks")}
+	store := marks.NewStore(config.MarkFilePath)
+	if _, err := store.Set('a', existing); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Set('b', missing); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCommand(t, runMarks, config, "", "list", "--json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var infos []markInfo
+	if err := json.Unmarshal([]byte(out), &infos); err != nil {
+		t.Fatalf("want JSON, got %q: %v", out, err)
+	}
+
+	wantInfos := []markInfo{
+		{Key: "a", Path: existing, Exists: true},
+		{Key: "b", Path: missing, Exists: false},
+	}
+	if !reflect.DeepEqual(infos, wantInfos) {
+		t.Errorf("want=%+v, got=%+v", wantInfos, infos)
+	}
+
+	out, err = runCommand(t, runMarks, config, "", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exported map[string]string
+	if err := json.Unmarshal([]byte(out), &exported); err != nil {
+		t.Fatalf("want JSON, got %q: %v", out, err)
+	}
+
+	wantExported := map[string]string{"a": existing, "b": missing}
+	if !reflect.DeepEqual(exported, wantExported) {
+		t.Errorf("want=%v, got=%v", wantExported, exported)
+	}
+
+	// What's exported can be imported again as it is.
+	other := &conf.Config{MarkFilePath: filepath.Join(dir, "other.marks")}
+	if _, err := runCommand(t, runMarks, other, out, "import", "--replace"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := marks.NewStore(other.MarkFilePath).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[rune]string{'a': existing, 'b': missing}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%v, got=%v", want, got)
+	}
+}
+
+func TestRunMarksUsage(t *testing.T) {
+	config := &conf.Config{MarkFilePath: filepath.Join(t.TempDir(), "pathsurfer.marks")}
+
+	data := [][]string{
+		{},
+		{"unknown"},
+		{"list", "extra"},
+		{"get"},
+		{"get", "ab"},
+		{"set", "a"},
+		{"rm"},
+		{"export", "extra"},
+		{"import", "a", "b"},
+	}
+
+	for _, args := range data {
+		if _, err := runCommand(t, runMarks, config, "", args...); err == nil {
+			t.Errorf("args=%q: want an error", args)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+)
+
+// checkOutput reports whether the output and picking options in config make
+// sense, so that a typo is caught before the navigator starts rather than
+// after the user has picked something.
+func checkOutput(config *conf.Config) error {
+	picks := 0
+	for _, set := range []bool{config.Pick, config.PickDir, config.PickFile} {
+		if set {
+			picks++
+		}
+	}
+	if picks > 1 {
+		return errors.New("only one of --pick, --pick-dir and --pick-file can be used")
+	}
+
+	switch config.Output {
+	case "lines":
+		return nil
+	case "json":
+		if config.Print0 {
+			return errors.New("--print0 can't be used with --output=json")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown output format %q (want lines or json)", config.Output)
+}
+
+// writePaths prints paths the way the output options in config ask for: one
+// per line, separated by NUL characters or as a JSON array.
+func writePaths(w io.Writer, config *conf.Config, paths []string) error {
+	if config.Output == "json" {
+		if paths == nil {
+			paths = []string{}
+		}
+		return json.NewEncoder(w).Encode(paths)
+	}
+
+	sep := "\n"
+	if config.Print0 {
+		sep = "\x00"
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, path := range paths {
+		bw.WriteString(path)
+		bw.WriteString(sep)
+	}
+
+	return bw.Flush()
+}
+
+// writePathsToFile writes paths to the file at path the same way writePaths
+// does, replacing whatever the file held before.
+func writePathsToFile(path string, config *conf.Config, paths []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := writePaths(f, config, paths); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// picking reports whether one of the picker options is set in config.
+func picking(config *conf.Config) bool {
+	return config.Pick || config.PickDir || config.PickFile
+}
+
+// clearOutputFile empties the output file, if there is one, when nothing is
+// going to be written to it. Otherwise, whatever an earlier run left there
+// would be taken for what was just picked.
+func clearOutputFile(config *conf.Config) error {
+	if config.OutputFile == "" {
+		return nil
+	}
+
+	err := os.Truncate(config.OutputFile, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
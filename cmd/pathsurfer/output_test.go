@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+)
+
+func TestClearOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "picked")
+	config := &conf.Config{OutputFile: path}
+
+	// There's nothing to clear before the first run.
+	if err := clearOutputFile(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("want no file to be created, got %v", err)
+	}
+
+	if err := writePathsToFile(path, &conf.Config{Output: "lines"}, []string{"/old/pick"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := clearOutputFile(config); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("want the file to be emptied, got %q", data)
+	}
+
+	if err := clearOutputFile(&conf.Config{}); err != nil {
+		t.Errorf("want nothing to be done without an output file, got %v", err)
+	}
+}
@@ -1,13 +1,17 @@
 package conf
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
+	"text/tabwriter"
 )
 
 var (
@@ -17,18 +21,72 @@ var (
 
 var DefaultLogFilePath string
 var DefaultMarkFilePath string
+var DefaultFrecencyFilePath string
+var DefaultHistoryFilePath string
+var DefaultPositionFilePath string
+var DefaultSortFilePath string
+var DefaultConfigFilePath string
+var DefaultIgnoreFilePath string
 
 const (
 	ProgramName = "pathsurfer"
+
+	DefaultFinderMaxDepth = 12
+	DefaultFinderExclude  = ".git,node_modules"
+	DefaultSortOrder      = "name"
+	DefaultOutput         = "lines"
 )
 
+// Each option can be set in the config file, through an environment variable
+// and through a command-line flag. The flag tag holds the name of the flag,
+// which is also the key used in the config file. The env tag holds the name of
+// the environment variable.
 type Config struct {
-	WriteDebugLogs  bool
-	LogFilePath     string
-	MarkFilePath    string
-	ShowHiddenFiles bool
+	WriteDebugLogs   bool   `flag:"debug" env:"PATHSURFER_DEBUG"`
+	LogFilePath      string `flag:"log-file" env:"PATHSURFER_LOG_FILE"`
+	MarkFilePath     string `flag:"mark-file" env:"PATHSURFER_MARK_FILE"`
+	FrecencyFilePath string `flag:"frecency-file" env:"PATHSURFER_FRECENCY_FILE"`
+	HistoryFilePath  string `flag:"history-file" env:"PATHSURFER_HISTORY_FILE"`
+	PositionFilePath string `flag:"position-file" env:"PATHSURFER_POSITION_FILE"`
+	ShowHiddenFiles  bool   `flag:"show-hidden-files" env:"PATHSURFER_SHOW_HIDDEN"`
+	FinderMaxDepth   int    `flag:"finder-max-depth" env:"PATHSURFER_FINDER_MAX_DEPTH"`
+	FinderExclude    string `flag:"finder-exclude" env:"PATHSURFER_FINDER_EXCLUDE"`
+	RespectIgnore    bool   `flag:"respect-ignore" env:"PATHSURFER_RESPECT_IGNORE"`
+	IgnoreFilePath   string `flag:"ignore-file" env:"PATHSURFER_IGNORE_FILE"`
+	SortOrder        string `flag:"sort" env:"PATHSURFER_SORT"`
+	SortFilePath     string `flag:"sort-file" env:"PATHSURFER_SORT_FILE"`
+	GitStatus        bool   `flag:"git-status" env:"PATHSURFER_GIT_STATUS"`
+	ShowInfo         bool   `flag:"show-info" env:"PATHSURFER_SHOW_INFO"`
+	Watch            bool   `flag:"watch" env:"PATHSURFER_WATCH"`
+	Output           string `flag:"output" env:"PATHSURFER_OUTPUT"`
+	Print0           bool   `flag:"print0" env:"PATHSURFER_PRINT0"`
+	OutputFile       string `flag:"output-file" env:"PATHSURFER_OUTPUT_FILE"`
+	Pick             bool   `flag:"pick" env:"PATHSURFER_PICK"`
+	PickDir          bool   `flag:"pick-dir" env:"PATHSURFER_PICK_DIR"`
+	PickFile         bool   `flag:"pick-file" env:"PATHSURFER_PICK_FILE"`
+	Prompt           string `flag:"prompt" env:"PATHSURFER_PROMPT"`
+
+	// Keymap holds the key bindings from the config file. It's keyed by mode
+	// and then by key sequence. The values are action names. It can only be
+	// set in the config file.
+	Keymap map[string]map[string]string `json:"keymap"`
+
+	// Syntax holds the styles of highlighted source code from the config file,
+	// keyed by the kind of token. It can only be set in the config file.
+	Syntax map[string]string `json:"syntax"`
+
+	// Sources records where the value of each option came from. It's keyed by
+	// the name of the option's flag.
+	Sources map[string]string `json:"-"`
 }
 
+const (
+	SourceDefault = "default"
+	SourceFile    = "config file"
+	SourceEnv     = "environment"
+	SourceFlag    = "command line"
+)
+
 func Init() (*Config, error) {
 	flag.Usage = func() {
 		cliOutput := flag.CommandLine.Output()
@@ -37,9 +95,18 @@ func Init() (*Config, error) {
 		fmt.Fprintln(cliOutput, "")
 		fmt.Fprintln(cliOutput, "Usage:")
 		fmt.Fprintln(cliOutput, "  psurf [options] [path]")
+		fmt.Fprintln(cliOutput, "  psurf [options] jump <query>")
+		fmt.Fprintln(cliOutput, "  psurf [options] marks list|get|set|rm|export|import")
+		fmt.Fprintln(cliOutput, "  psurf [options] filter [--dir <path>] [--json] <pattern>")
+		fmt.Fprintln(cliOutput, "")
+		fmt.Fprintln(cliOutput, "A directory named like a subcommand can be opened as ./jump or as -- jump.")
 		fmt.Fprintln(cliOutput, "")
 		fmt.Fprintln(cliOutput, "Options:")
 		flag.PrintDefaults()
+		fmt.Fprintln(cliOutput, "")
+		fmt.Fprintln(cliOutput, "Every option can also be set in the config file or through an environment")
+		fmt.Fprintln(cliOutput, "variable. Flags take precedence over environment variables, which take")
+		fmt.Fprintln(cliOutput, "precedence over the config file. Use --print-config to see the result.")
 	}
 
 	home, err := os.UserHomeDir()
@@ -61,33 +128,56 @@ func Init() (*Config, error) {
 		ProgramName,
 		fmt.Sprintf("%s.mark", ProgramName),
 	)
-
-	result := &Config{}
-	flag.BoolVar(
-		&result.WriteDebugLogs,
-		"debug",
-		false,
-		"Determines whether debug logs are enabled (set to false by default)",
+	DefaultFrecencyFilePath = filepath.Join(
+		home,
+		".local",
+		"share",
+		ProgramName,
+		fmt.Sprintf("%s.frecency", ProgramName),
 	)
-	flag.BoolVar(
-		&result.ShowHiddenFiles,
-		"show-hidden-files",
-		false,
-		"Determines whether hidden files are shown (set to false by default)",
+	DefaultHistoryFilePath = filepath.Join(
+		home,
+		".local",
+		"share",
+		ProgramName,
+		fmt.Sprintf("%s.history", ProgramName),
 	)
-	flag.StringVar(
-		&result.LogFilePath,
-		"log-file",
-		DefaultLogFilePath,
-		"The path of the file used for storing logs",
+	DefaultPositionFilePath = filepath.Join(
+		home,
+		".local",
+		"share",
+		ProgramName,
+		fmt.Sprintf("%s.positions", ProgramName),
 	)
-	flag.StringVar(
-		&result.MarkFilePath,
-		"mark-file",
-		DefaultMarkFilePath,
-		"The path of the file used for storing marks",
+	DefaultSortFilePath = filepath.Join(
+		home,
+		".local",
+		"share",
+		ProgramName,
+		fmt.Sprintf("%s.sort", ProgramName),
 	)
 
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	DefaultConfigFilePath = filepath.Join(configHome, ProgramName, "config.json")
+	// The same global ignore file that git uses by default.
+	DefaultIgnoreFilePath = filepath.Join(configHome, "git", "ignore")
+
+	result := &Config{}
+	defineFlags(flag.CommandLine, result)
+
+	configFilePath := flag.String(
+		"config",
+		DefaultConfigFilePath,
+		"The path of the config file",
+	)
+	displayConfig := flag.Bool(
+		"print-config",
+		false,
+		"Show the effective configuration and where each value came from",
+	)
 	displayVersion := flag.Bool(
 		"version",
 		false,
@@ -113,28 +203,277 @@ func Init() (*Config, error) {
 		os.Exit(0)
 	}
 
+	// The default config file is optional, but one that was asked for
+	// explicitly has to exist.
+	configFileRequired := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			configFileRequired = true
+		}
+	})
+
+	fileValues, err := readConfigFile(*configFilePath, configFileRequired, result)
+	if err != nil {
+		return result, err
+	}
+
+	err = applyLayers(flag.CommandLine, result, fileValues, os.LookupEnv)
+	if err != nil {
+		return result, err
+	}
+
+	if *displayConfig {
+		if err := printConfig(os.Stdout, *result, *configFilePath); err != nil {
+			return result, err
+		}
+		os.Exit(0)
+	}
+
 	return result, nil
 }
 
-func printConfig(c Config) error {
-	configToPrint := Config{}
-	valToPrint := reflect.ValueOf(&configToPrint).Elem()
-	valToInspect := reflect.ValueOf(c)
+func defineFlags(fs *flag.FlagSet, result *Config) {
+	fs.BoolVar(
+		&result.WriteDebugLogs,
+		"debug",
+		false,
+		"Determines whether debug logs are enabled (set to false by default)",
+	)
+	fs.BoolVar(
+		&result.ShowHiddenFiles,
+		"show-hidden-files",
+		false,
+		"Determines whether hidden files are shown (set to false by default)",
+	)
+	fs.StringVar(
+		&result.LogFilePath,
+		"log-file",
+		DefaultLogFilePath,
+		"The path of the file used for storing logs",
+	)
+	fs.StringVar(
+		&result.MarkFilePath,
+		"mark-file",
+		DefaultMarkFilePath,
+		"The path of the file used for storing marks",
+	)
+	fs.StringVar(
+		&result.FrecencyFilePath,
+		"frecency-file",
+		DefaultFrecencyFilePath,
+		"The path of the file used for keeping track of frequently visited directories",
+	)
+	fs.StringVar(
+		&result.HistoryFilePath,
+		"history-file",
+		DefaultHistoryFilePath,
+		"The path of the file used for keeping the history of visited directories between sessions",
+	)
+	fs.StringVar(
+		&result.PositionFilePath,
+		"position-file",
+		DefaultPositionFilePath,
+		"The path of the file used for remembering the selected entry in each directory between sessions",
+	)
+	fs.IntVar(
+		&result.FinderMaxDepth,
+		"finder-max-depth",
+		DefaultFinderMaxDepth,
+		"How many directories deep the finder looks (0 means no limit)",
+	)
+	fs.StringVar(
+		&result.FinderExclude,
+		"finder-exclude",
+		DefaultFinderExclude,
+		"Comma-separated names or glob patterns that the finder skips",
+	)
+	fs.BoolVar(
+		&result.RespectIgnore,
+		"respect-ignore",
+		true,
+		"Determines whether files matched by .gitignore, .ignore and the global ignore file are hidden",
+	)
+	fs.StringVar(
+		&result.IgnoreFilePath,
+		"ignore-file",
+		DefaultIgnoreFilePath,
+		"The path of the global ignore file",
+	)
+	fs.StringVar(
+		&result.SortOrder,
+		"sort",
+		DefaultSortOrder,
+		"How listings are sorted, e.g. natural,dirs-first (one of name, natural, time, size or extension plus any of reverse, dirs-first and ignore-case)",
+	)
+	fs.StringVar(
+		&result.SortFilePath,
+		"sort-file",
+		DefaultSortFilePath,
+		"The path of the file used for remembering the sort order of each directory",
+	)
+	fs.BoolVar(
+		&result.GitStatus,
+		"git-status",
+		true,
+		"Determines whether the status of files in git work trees is shown",
+	)
+	fs.BoolVar(
+		&result.ShowInfo,
+		"show-info",
+		false,
+		"Determines whether details about the selected entry are shown below the path",
+	)
+	fs.BoolVar(
+		&result.Watch,
+		"watch",
+		true,
+		"Determines whether listings are refreshed when the directories change",
+	)
+	fs.StringVar(
+		&result.Output,
+		"output",
+		DefaultOutput,
+		"How printed paths are formatted (lines or json)",
+	)
+	fs.BoolVar(
+		&result.Print0,
+		"print0",
+		false,
+		"Separate printed paths with NUL characters instead of newlines",
+	)
+	fs.StringVar(
+		&result.OutputFile,
+		"output-file",
+		"",
+		"Write the printed paths to this file instead of stdout",
+	)
+	fs.BoolVar(
+		&result.Pick,
+		"pick",
+		false,
+		"Pick entries instead of changing directories: enter prints them, q and Ctrl-C cancel",
+	)
+	fs.BoolVar(
+		&result.PickDir,
+		"pick-dir",
+		false,
+		"Like --pick, but only directories can be picked",
+	)
+	fs.BoolVar(
+		&result.PickFile,
+		"pick-file",
+		false,
+		"Like --pick, but only files can be picked",
+	)
+	fs.StringVar(
+		&result.Prompt,
+		"prompt",
+		"",
+		"Text shown at the top in place of the mode, e.g. to say what's being picked",
+	)
+}
+
+// FinderExcludePatterns returns the patterns in FinderExclude.
+func (c *Config) FinderExcludePatterns() []string {
+	result := []string{}
+	for _, pattern := range strings.Split(c.FinderExclude, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			result = append(result, pattern)
+		}
+	}
+
+	return result
+}
+
+// applyLayers fills in every option of c that wasn't set on the command line,
+// first from the environment and then from the config file. The flags in fs
+// must have been defined with defineFlags and parsed already.
+func applyLayers(
+	fs *flag.FlagSet,
+	c *Config,
+	fileValues map[string]string,
+	lookupEnv func(string) (string, bool),
+) error {
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	c.Sources = make(map[string]string)
+	configType := reflect.TypeOf(*c)
+
+	for i := range configType.NumField() {
+		field := configType.Field(i)
+		name := field.Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+
+		if setFlags[name] {
+			c.Sources[name] = SourceFlag
+			continue
+		}
+
+		env := field.Tag.Get("env")
+		if value, ok := lookupEnv(env); ok && env != "" {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("invalid value %q for %s: %w", value, env, err)
+			}
+
+			c.Sources[name] = fmt.Sprintf("%s %s", SourceEnv, env)
+			continue
+		}
+
+		if value, ok := fileValues[name]; ok {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("invalid value %q for %q in the config file: %w", value, name, err)
+			}
 
+			c.Sources[name] = SourceFile
+			continue
+		}
+
+		c.Sources[name] = SourceDefault
+	}
+
+	return nil
+}
+
+func printConfig(w io.Writer, c Config, configFilePath string) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "build time:\t%v\n", buildTime)
+	fmt.Fprintf(tw, "version:\t%v\n", version)
+	fmt.Fprintf(tw, "config file:\t%v\n", configFilePath)
+	fmt.Fprintln(tw, "")
+
+	valToInspect := reflect.ValueOf(c)
 	for i := range valToInspect.NumField() {
-		if valToInspect.Type().Field(i).Tag.Get("sensitive") != "yes" {
-			valToPrint.Field(i).Set(valToInspect.Field(i))
+		field := valToInspect.Type().Field(i)
+		name := field.Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+
+		value := valToInspect.Field(i).Interface()
+		if field.Tag.Get("sensitive") == "yes" {
+			value = "<hidden>"
 		}
+
+		fmt.Fprintf(tw, "%s\t%v\t(%s)\n", name, value, c.Sources[name])
 	}
 
-	b, err := json.Marshal(configToPrint)
-	if err != nil {
-		return err
+	modes := slices.Sorted(maps.Keys(c.Keymap))
+	for _, mode := range modes {
+		bindings := c.Keymap[mode]
+		for _, keys := range slices.Sorted(maps.Keys(bindings)) {
+			fmt.Fprintf(tw, "keymap.%s.%s\t%q\t(%s)\n", mode, keys, bindings[keys], SourceFile)
+		}
 	}
 
-	log.Printf("build time: %v", buildTime)
-	log.Printf("version: %v", version)
-	log.Printf("config: %v", string(b))
+	for _, kind := range slices.Sorted(maps.Keys(c.Syntax)) {
+		fmt.Fprintf(tw, "syntax.%s\t%q\t(%s)\n", kind, c.Syntax[kind], SourceFile)
+	}
 
-	return nil
+	return tw.Flush()
 }
@@ -0,0 +1,160 @@
+package conf
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyLayers(t *testing.T) {
+	fileValues := map[string]string{
+		"show-hidden-files": "true",
+		"mark-file":         "/from/file.mark",
+		"log-file":          "/from/file.log",
+	}
+	env := map[string]string{
+		"PATHSURFER_SHOW_HIDDEN": "false",
+		"PATHSURFER_MARK_FILE":   "/from/env.mark",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c := &Config{}
+	defineFlags(fs, c)
+
+	if err := fs.Parse([]string{"--mark-file", "/from/flag.mark"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyLayers(fs, c, fileValues, lookupEnv); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Config{
+		WriteDebugLogs:   false,
+		LogFilePath:      "/from/file.log",
+		MarkFilePath:     "/from/flag.mark",
+		FrecencyFilePath: DefaultFrecencyFilePath,
+		HistoryFilePath:  DefaultHistoryFilePath,
+		PositionFilePath: DefaultPositionFilePath,
+		ShowHiddenFiles:  false,
+		FinderMaxDepth:   DefaultFinderMaxDepth,
+		FinderExclude:    DefaultFinderExclude,
+		RespectIgnore:    true,
+		IgnoreFilePath:   DefaultIgnoreFilePath,
+		SortOrder:        DefaultSortOrder,
+		SortFilePath:     DefaultSortFilePath,
+		GitStatus:        true,
+		Watch:            true,
+		Output:           DefaultOutput,
+		Sources: map[string]string{
+			"debug":             SourceDefault,
+			"log-file":          SourceFile,
+			"mark-file":         SourceFlag,
+			"frecency-file":     SourceDefault,
+			"history-file":      SourceDefault,
+			"position-file":     SourceDefault,
+			"show-hidden-files": SourceEnv + " PATHSURFER_SHOW_HIDDEN",
+			"finder-max-depth":  SourceDefault,
+			"finder-exclude":    SourceDefault,
+			"respect-ignore":    SourceDefault,
+			"ignore-file":       SourceDefault,
+			"sort":              SourceDefault,
+			"sort-file":         SourceDefault,
+			"git-status":        SourceDefault,
+			"show-info":         SourceDefault,
+			"watch":             SourceDefault,
+			"output":            SourceDefault,
+			"print0":            SourceDefault,
+			"output-file":       SourceDefault,
+			"pick":              SourceDefault,
+			"pick-dir":          SourceDefault,
+			"pick-file":         SourceDefault,
+			"prompt":            SourceDefault,
+		},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("want=%+v, got=%+v", want, c)
+	}
+}
+
+func TestApplyLayersRejectsInvalidValues(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c := &Config{}
+	defineFlags(fs, c)
+
+	noEnv := func(string) (string, bool) { return "", false }
+	err := applyLayers(fs, c, map[string]string{"debug": "maybe"}, noEnv)
+	if err == nil {
+		t.Errorf("want an error for an invalid boolean")
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	got, err := readConfigFile(path, false, &Config{})
+	if err != nil || len(got) != 0 {
+		t.Errorf("want no values and no error for a missing file, got=%v, err=%v", got, err)
+	}
+	if _, err := readConfigFile(path, true, &Config{}); err == nil {
+		t.Errorf("want an error for a missing required file")
+	}
+
+	contents := `{"show-hidden-files": true, "mark-file": "/path with spaces/marks", "output-file": null, "syntax": {"keyword": "red bold"}}`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{}
+	got, err = readConfigFile(path, true, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"show-hidden-files": "true", "mark-file": "/path with spaces/marks"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%v, got=%v", want, got)
+	}
+	if wantSyntax := map[string]string{"keyword": "red bold"}; !reflect.DeepEqual(c.Syntax, wantSyntax) {
+		t.Errorf("syntax: want=%v, got=%v", wantSyntax, c.Syntax)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"colour": "red"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readConfigFile(path, true, &Config{}); err == nil {
+		t.Errorf("want an error for an unknown option")
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	c := Config{
+		ShowHiddenFiles: true,
+		Sources:         map[string]string{"show-hidden-files": SourceFile},
+	}
+
+	var b bytes.Buffer
+	if err := printConfig(&b, c, "/config.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(b.String(), "show-hidden-files  true") || !strings.Contains(b.String(), "(config file)") {
+		t.Errorf("want the option, its value and its source, got:\n%s", b.String())
+	}
+}
+
+func TestFinderExcludePatterns(t *testing.T) {
+	c := Config{FinderExclude: " .git, node_modules,,*.o "}
+
+	want := []string{".git", "node_modules", "*.o"}
+	if got := c.FinderExcludePatterns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%q, got=%q", want, got)
+	}
+}
@@ -0,0 +1,97 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// readConfigFile reads the JSON config file at path and returns the value of
+// every option in it as a string, ready to be passed to flag.Set. Keys are the
+// names of the flags. Options set to null are left unset. A missing file isn't
+// an error unless required is true.
+//
+// Settings that can only be set in the config file, such as the keymap and the
+// syntax styles, are stored in c directly.
+//
+// An example config file looks like this:
+//
+//	{
+//	    "show-hidden-files": true,
+//	    "mark-file": "/home/user/sync/pathsurfer.mark",
+//	    "keymap": {
+//	        "default": {"<Down>": "move-down", "<C-n>": "move-down"}
+//	    },
+//	    "syntax": {"keyword": "#ff8700 bold", "comment": "gray on black"}
+//	}
+func readConfigFile(path string, required bool, c *Config) (map[string]string, error) {
+	result := make(map[string]string)
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return result, fmt.Errorf("reading config file %q: %w", path, err)
+	}
+
+	for key, value := range raw {
+		switch key {
+		case "keymap":
+			if err := json.Unmarshal(value, &c.Keymap); err != nil {
+				return result, fmt.Errorf("reading config file %q: %q: %w", path, key, err)
+			}
+			continue
+		case "syntax":
+			if err := json.Unmarshal(value, &c.Syntax); err != nil {
+				return result, fmt.Errorf("reading config file %q: %q: %w", path, key, err)
+			}
+			continue
+		}
+
+		if !isOption(key) {
+			return result, fmt.Errorf("reading config file %q: unknown option %q", path, key)
+		}
+
+		value = bytes.TrimSpace(value)
+
+		switch {
+		case bytes.Equal(value, []byte("null")):
+			continue
+
+		case bytes.HasPrefix(value, []byte(`"`)):
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return result, fmt.Errorf("reading config file %q: %q: %w", path, key, err)
+			}
+			result[key] = s
+
+		case bytes.HasPrefix(value, []byte("{")), bytes.HasPrefix(value, []byte("[")):
+			return result, fmt.Errorf("reading config file %q: %q must be a string, a number or a boolean", path, key)
+
+		default:
+			result[key] = string(value)
+		}
+	}
+
+	return result, nil
+}
+
+func isOption(name string) bool {
+	configType := reflect.TypeOf(Config{})
+
+	for i := range configType.NumField() {
+		if configType.Field(i).Tag.Get("flag") == name {
+			return true
+		}
+	}
+
+	return false
+}
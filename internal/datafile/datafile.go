@@ -0,0 +1,144 @@
+// Package datafile reads and writes the small line-based files that
+// pathsurfer keeps its data in, such as marks and visited directories.
+package datafile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Replace replaces the file at path with whatever write writes. It writes to a
+// temporary file in the same directory and renames it so that other running
+// instances never see a partially written file. The file keeps its
+// permissions, or gets 0644 if it's new. Missing parent directories are
+// created.
+func Replace(path string, write func(w io.Writer) error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := write(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// CreateTemp creates files that only the owner can read.
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// WithLock runs fn while holding a lock on the file at path, so that other
+// instances can't change it in between. The lock is exclusive if exclusive is
+// set and shared otherwise. It's taken on a file next to path since path
+// itself gets replaced by Replace.
+func WithLock(path string, exclusive bool, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	if err := lockFile(lock, exclusive); err != nil {
+		return fmt.Errorf("locking %q: %w", lock.Name(), err)
+	}
+	defer unlockFile(lock)
+
+	return fn()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// Escape escapes backslashes, tabs, newlines and carriage returns the same way
+// as in Go strings so that s can be stored as a field of a tab-separated line.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// Unescape reverses Escape.
+func Unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		if i+1 == len(s) {
+			return "", fmt.Errorf("trailing backslash in %q", s)
+		}
+
+		i++
+		switch s[i] {
+		case '\\':
+			sb.WriteByte('\\')
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c in %q", s[i], s)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// Fields splits a line written with Escape into its n tab-separated fields and
+// unescapes each of them.
+func Fields(line string, n int) ([]string, error) {
+	fields := strings.SplitN(line, "\t", n)
+	if len(fields) != n {
+		return nil, fmt.Errorf("contains less than %v components", n)
+	}
+
+	for i, field := range fields {
+		var err error
+		if fields[i], err = Unescape(field); err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
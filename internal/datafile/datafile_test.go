@@ -0,0 +1,149 @@
+package datafile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	data := []string{
+		"",
+		"/plain/path",
+		"/with\ttab",
+		"/with\nnewline\r",
+		`C:\with\backslashes\`,
+		`\t is not a tab`,
+	}
+
+	for _, s := range data {
+		got, err := Unescape(Escape(s))
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+		} else if got != s {
+			t.Errorf("want=%q, got=%q", s, got)
+		}
+	}
+}
+
+func TestUnescapeInvalid(t *testing.T) {
+	for _, s := range []string{`trailing\`, `unknown\x`} {
+		if _, err := Unescape(s); err == nil {
+			t.Errorf("%q: want an error", s)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	data := []struct {
+		Line    string
+		Want    []string
+		WantErr bool
+	}{
+		{"a\tb", []string{"a", "b"}, false},
+		{"a\tb\tc", []string{"a", "b\tc"}, false},
+		{`a\tb` + "\t" + `c\nd`, []string{"a\tb", "c\nd"}, false},
+		{"\t/a", []string{"", "/a"}, false},
+		{"no tab", nil, true},
+		{`a` + "\t" + `b\`, nil, true},
+	}
+
+	for _, tt := range data {
+		got, err := Fields(tt.Line, 2)
+		if (err != nil) != tt.WantErr {
+			t.Errorf("%q: want error=%v, got %v", tt.Line, tt.WantErr, err)
+		} else if !reflect.DeepEqual(got, tt.Want) {
+			t.Errorf("%q: want=%q, got=%q", tt.Line, tt.Want, got)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data", "file")
+
+	for _, content := range []string{"first\n", "second\n"} {
+		err := Replace(path, func(w io.Writer) error {
+			_, err := io.WriteString(w, content)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("want=%q, got=%q", content, got)
+		}
+	}
+
+	failed := errors.New("failed")
+	err := Replace(path, func(w io.Writer) error {
+		fmt.Fprint(w, "partial")
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("want the error from write, got %v", err)
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != "second\n" {
+		t.Errorf("want the file to be left alone after a failed write, got %q", got)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("want no temporary files left behind, got %v", entries)
+	}
+}
+
+func TestReplaceKeepsPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows only has a read-only bit")
+	}
+
+	path := filepath.Join(t.TempDir(), "file")
+	write := func(w io.Writer) error {
+		_, err := io.WriteString(w, "data\n")
+		return err
+	}
+
+	if err := Replace(path, write); err != nil {
+		t.Fatal(err)
+	}
+	if got := perm(t, path); got != 0644 {
+		t.Errorf("want a new file to be 0644, got %v", got)
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replace(path, write); err != nil {
+		t.Fatal(err)
+	}
+	if got := perm(t, path); got != 0600 {
+		t.Errorf("want the file to stay 0600, got %v", got)
+	}
+}
+
+func perm(t *testing.T, path string) fs.FileMode {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return info.Mode().Perm()
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package datafile
+
+import "os"
+
+// File locking isn't supported on this platform. Writes are still atomic, but
+// two instances updating a file at the same time might lose one of the updates.
+
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
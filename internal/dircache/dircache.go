@@ -0,0 +1,148 @@
+// Package dircache keeps the listings of recently read directories around so
+// that moving the cursor back and forth doesn't read them again.
+package dircache
+
+import (
+	"container/list"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// Listing is what's remembered about a directory. ModTime is the modification
+// time of the directory when it was read, which changes whenever an entry is
+// added, removed or renamed.
+type Listing struct {
+	Entries []fs.DirEntry
+	Ignored map[string]bool
+	ModTime time.Time
+}
+
+// Stats counts the lookups done with Get.
+type Stats struct {
+	Hits     int
+	Misses   int
+	Listings int
+	Entries  int
+}
+
+// Cache is a least recently used cache of listings keyed by path. It's safe
+// for concurrent use. Listings must not be changed once they've been added.
+type Cache struct {
+	maxListings int
+	maxEntries  int
+
+	mu      sync.Mutex
+	order   *list.List // Of *item, most recently used first.
+	items   map[string]*list.Element
+	entries int
+	stats   Stats
+}
+
+type item struct {
+	path    string
+	listing Listing
+	// stale is set for listings that are known to be out of date but can
+	// still be shown until they're read again.
+	stale bool
+}
+
+// New returns a cache that holds at most maxListings listings with at most
+// maxEntries entries between them. The least recently used listings are
+// dropped first, but the last one added is always kept.
+func New(maxListings, maxEntries int) *Cache {
+	return &Cache{
+		maxListings: maxListings,
+		maxEntries:  maxEntries,
+		order:       list.New(),
+		items:       make(map[string]*list.Element),
+	}
+}
+
+// Get returns the listing of path if it was read when the directory was last
+// modified at modTime. Anything else counts as a miss.
+func (c *Cache) Get(path string, modTime time.Time) (Listing, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[path]
+	if !ok || elem.Value.(*item).stale || !elem.Value.(*item).listing.ModTime.Equal(modTime) {
+		c.stats.Misses++
+		return Listing{}, false
+	}
+
+	c.stats.Hits++
+	c.order.MoveToFront(elem)
+
+	return elem.Value.(*item).listing, true
+}
+
+// Peek returns the listing of path, even if it might be out of date. It isn't
+// counted as a lookup.
+func (c *Cache) Peek(path string) (Listing, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[path]
+	if !ok {
+		return Listing{}, false
+	}
+
+	return elem.Value.(*item).listing, true
+}
+
+// Put adds the listing of path, replacing the one that was there before.
+func (c *Cache) Put(path string, listing Listing) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[path]; ok {
+		c.remove(elem)
+	}
+
+	c.items[path] = c.order.PushFront(&item{path: path, listing: listing})
+	c.entries += len(listing.Entries)
+
+	for c.order.Len() > 1 && (c.order.Len() > c.maxListings || c.entries > c.maxEntries) {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate marks the listing of path as out of date. Peek still returns it,
+// but Get doesn't.
+func (c *Cache) Invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[path]; ok {
+		elem.Value.(*item).stale = true
+	}
+}
+
+// Clear drops every listing. The stats are kept.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.items)
+	c.entries = 0
+}
+
+// Stats returns the lookups counted so far along with what's in the cache.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := c.stats
+	result.Listings = c.order.Len()
+	result.Entries = c.entries
+
+	return result
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	it := c.order.Remove(elem).(*item)
+	delete(c.items, it.path)
+	c.entries -= len(it.listing.Entries)
+}
@@ -0,0 +1,84 @@
+package dircache
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func listing(t *testing.T, modTime time.Time, names ...string) Listing {
+	t.Helper()
+
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys[name] = &fstest.MapFile{}
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Listing{Entries: entries, ModTime: modTime}
+}
+
+func TestCache(t *testing.T) {
+	c := New(2, 100)
+	t1 := time.Unix(1, 0)
+	t2 := time.Unix(2, 0)
+
+	c.Put("/a", listing(t, t1, "x", "y"))
+
+	if got, ok := c.Get("/a", t1); !ok || len(got.Entries) != 2 {
+		t.Errorf("want a hit with 2 entries, got ok=%v %+v", ok, got)
+	}
+	if _, ok := c.Get("/a", t2); ok {
+		t.Errorf("want a miss once the directory has been modified")
+	}
+	if _, ok := c.Get("/b", t1); ok {
+		t.Errorf("want a miss for a directory that hasn't been added")
+	}
+
+	c.Invalidate("/a")
+	if _, ok := c.Get("/a", t1); ok {
+		t.Errorf("want a miss once the listing has been invalidated")
+	}
+	if _, ok := c.Peek("/a"); !ok {
+		t.Errorf("want an invalidated listing to be peekable")
+	}
+
+	want := Stats{Hits: 1, Misses: 3, Listings: 1, Entries: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("want=%+v, got=%+v", want, got)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := New(2, 5)
+	t1 := time.Unix(1, 0)
+
+	c.Put("/a", listing(t, t1, "1"))
+	c.Put("/b", listing(t, t1, "1"))
+	c.Get("/a", t1)
+	c.Put("/c", listing(t, t1, "1"))
+
+	// /b is the least recently used one.
+	for path, want := range map[string]bool{"/a": true, "/b": false, "/c": true} {
+		if _, ok := c.Peek(path); ok != want {
+			t.Errorf("path=%q: want present=%v, got %v", path, want, ok)
+		}
+	}
+
+	// Too many entries push everything else out, but the newest listing is
+	// kept no matter how big it is.
+	c.Put("/big", listing(t, t1, "1", "2", "3", "4", "5", "6"))
+	if got := c.Stats(); got.Listings != 1 || got.Entries != 6 {
+		t.Errorf("want only the big listing to be left, got %+v", got)
+	}
+
+	c.Clear()
+	if got := c.Stats(); got.Listings != 0 || got.Entries != 0 {
+		t.Errorf("want an empty cache, got %+v", got)
+	}
+}
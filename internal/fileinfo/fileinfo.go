@@ -0,0 +1,107 @@
+// Package fileinfo describes single files and adds up what's below
+// directories.
+package fileinfo
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Info is what's shown about the selected entry. Owner and Group are empty on
+// platforms without them.
+type Info struct {
+	Mode    fs.FileMode
+	Size    int64
+	ModTime time.Time
+	Owner   string
+	Group   string
+
+	// LinkTarget is where a symbolic link points to. It's empty for anything
+	// else.
+	LinkTarget string
+}
+
+// Stat describes the file at path without following symbolic links.
+func Stat(path string) (Info, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Info{}, err
+	}
+
+	result := Info{
+		Mode:    info.Mode(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	result.Owner, result.Group = owner(info)
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		result.LinkTarget, err = os.Readlink(path)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// Usage adds up everything below a directory. Size is the sum of the apparent
+// sizes of the files. Symbolic links count as files and aren't followed.
+type Usage struct {
+	Size  int64
+	Files int
+	Dirs  int
+}
+
+// progressInterval is how often DiskUsage reports what it has counted so far.
+const progressInterval = 100 * time.Millisecond
+
+// DiskUsage adds up everything below root. If progress isn't nil, it's called
+// with the totals so far every now and then while counting. Directories below
+// root that can't be read are skipped. If ctx is cancelled, DiskUsage stops and
+// returns ctx.Err().
+func DiskUsage(ctx context.Context, root string, progress func(Usage)) (Usage, error) {
+	result := Usage{}
+	lastReport := time.Now()
+
+	pending := []string{root}
+	for len(pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		dir := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if dir == root {
+				return result, err
+			}
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				result.Dirs++
+				pending = append(pending, filepath.Join(dir, entry.Name()))
+				continue
+			}
+
+			result.Files++
+			if info, err := entry.Info(); err == nil {
+				result.Size += info.Size()
+			}
+		}
+
+		if progress != nil && time.Since(lastReport) >= progressInterval {
+			progress(result)
+			lastReport = time.Now()
+		}
+	}
+
+	return result, nil
+}
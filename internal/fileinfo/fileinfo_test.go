@@ -0,0 +1,89 @@
+package fileinfo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func makeTree(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]int{
+		"a.txt":         10,
+		"sub/b.txt":     20,
+		"sub/deep/c.go": 30,
+	}
+
+	for name, size := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestStat(t *testing.T) {
+	root := makeTree(t)
+
+	info, err := Stat(filepath.Join(root, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 10 || !info.Mode.IsRegular() || info.LinkTarget != "" {
+		t.Errorf("unexpected info for a.txt: %+v", info)
+	}
+
+	info, err = Stat(filepath.Join(root, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LinkTarget != "sub" || info.Mode&os.ModeSymlink == 0 {
+		t.Errorf("unexpected info for link: %+v", info)
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	root := makeTree(t)
+
+	got, err := DiskUsage(context.Background(), root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The link counts as a file, but its size depends on the length of its
+	// target.
+	linkInfo, err := os.Lstat(filepath.Join(root, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Usage{Size: 60 + linkInfo.Size(), Files: 4, Dirs: 3}
+	if got != want {
+		t.Errorf("want=%+v, got=%+v", want, got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DiskUsage(ctx, root, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("want=%v, got=%v", context.Canceled, err)
+	}
+
+	if _, err := DiskUsage(context.Background(), filepath.Join(root, "missing"), nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want=%v, got=%v", os.ErrNotExist, err)
+	}
+}
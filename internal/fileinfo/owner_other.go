@@ -0,0 +1,12 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package fileinfo
+
+import "io/fs"
+
+// Files don't have a user and a group that own them on this platform, or at
+// least not ones that fit in a short line.
+
+func owner(info fs.FileInfo) (string, string) {
+	return "", ""
+}
@@ -0,0 +1,49 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package fileinfo
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+var (
+	namesMu    sync.Mutex
+	userNames  = make(map[uint32]string)
+	groupNames = make(map[uint32]string)
+)
+
+// owner returns the names of the user and the group that own a file, or their
+// IDs if they don't have names. Names are looked up once per ID.
+func owner(info fs.FileInfo) (string, string) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+
+	namesMu.Lock()
+	defer namesMu.Unlock()
+
+	userName, ok := userNames[stat.Uid]
+	if !ok {
+		userName = strconv.FormatUint(uint64(stat.Uid), 10)
+		if u, err := user.LookupId(userName); err == nil {
+			userName = u.Username
+		}
+		userNames[stat.Uid] = userName
+	}
+
+	groupName, ok := groupNames[stat.Gid]
+	if !ok {
+		groupName = strconv.FormatUint(uint64(stat.Gid), 10)
+		if g, err := user.LookupGroupId(groupName); err == nil {
+			groupName = g.Name
+		}
+		groupNames[stat.Gid] = groupName
+	}
+
+	return userName, groupName
+}
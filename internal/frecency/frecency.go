@@ -0,0 +1,269 @@
+package frecency
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bnuredini/pathsurfer/internal/datafile"
+	"github.com/bnuredini/pathsurfer/internal/fuzzy"
+)
+
+// Once the visit counts of all entries add up to more than this, every count
+// is scaled down so that directories that haven't been visited in a while
+// eventually drop out of the database.
+const MaxTotalVisits = 10000
+
+const agingFactor = 0.9
+
+type Entry struct {
+	Path      string
+	Visits    float64
+	LastVisit time.Time
+}
+
+// Score combines how often and how recently a directory was visited. Recent
+// visits are weighted more heavily in the same way zoxide does it.
+func (e Entry) Score(now time.Time) float64 {
+	age := now.Sub(e.LastVisit)
+
+	switch {
+	case age < time.Hour:
+		return e.Visits * 4
+	case age < 24*time.Hour:
+		return e.Visits * 2
+	case age < 7*24*time.Hour:
+		return e.Visits / 2
+	default:
+		return e.Visits / 4
+	}
+}
+
+// Store is the set of visited directories kept in a file. Each line in the
+// file holds the visit count, the time of the last visit as a Unix timestamp
+// and the path escaped with datafile.Escape, separated by tabs.
+type Store struct {
+	filePath string
+	entries  map[string]*Entry
+}
+
+// Load reads the store kept in filePath. Lines that can't be read are skipped.
+// A missing file results in an empty store.
+func Load(filePath string) (*Store, error) {
+	result := &Store{filePath: filePath, entries: make(map[string]*Entry)}
+
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+	defer f.Close()
+
+	lineIdx := 0
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		lineIdx++
+
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		entry, err := parseEntry(line)
+		if err != nil {
+			slog.Debug("Skipping an unreadable line of the frecency file", "path", filePath, "line", lineIdx, "err", err)
+			continue
+		}
+
+		result.entries[entry.Path] = entry
+	}
+
+	return result, scanner.Err()
+}
+
+// parseEntry reads an entry from a line of the file kept by a Store.
+func parseEntry(line string) (*Entry, error) {
+	fields, err := datafile.Fields(line, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	visits, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	lastVisit, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Entry{Path: fields[2], Visits: visits, LastVisit: time.Unix(lastVisit, 0)}, nil
+}
+
+// Visit records a visit to path.
+func (s *Store) Visit(path string, now time.Time) {
+	entry, ok := s.entries[path]
+	if !ok {
+		entry = &Entry{Path: path}
+		s.entries[path] = entry
+	}
+
+	entry.Visits++
+	entry.LastVisit = now
+
+	s.age()
+}
+
+// Remove drops path from the store.
+func (s *Store) Remove(path string) {
+	delete(s.entries, path)
+}
+
+// Entries returns every entry, the highest scoring first.
+func (s *Store) Entries(now time.Time) []Entry {
+	result := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		result = append(result, *entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Score(now) > result[j].Score(now)
+	})
+
+	return result
+}
+
+// Query returns the entries matching query, the best match first. The query is
+// fuzzy-matched against the last component of each path. If nothing matches
+// that way, it's matched against the whole path instead. Matches are ranked by
+// multiplying the match score with the frecency score.
+func (s *Store) Query(query string, now time.Time) []Entry {
+	byBase := make(map[string][]*Entry)
+	bases := []string{}
+	paths := []string{}
+
+	for path, entry := range s.entries {
+		base := filepath.Base(path)
+		if _, ok := byBase[base]; !ok {
+			bases = append(bases, base)
+		}
+
+		byBase[base] = append(byBase[base], entry)
+		paths = append(paths, path)
+	}
+
+	type ranked struct {
+		entry Entry
+		rank  float64
+	}
+	results := []ranked{}
+
+	for _, match := range fuzzy.Find(query, bases) {
+		for _, entry := range byBase[match.CandidateString] {
+			results = append(results, ranked{*entry, entry.Score(now) * float64(max(match.Score, 1))})
+		}
+	}
+
+	if len(results) == 0 {
+		for _, match := range fuzzy.Find(query, paths) {
+			entry := s.entries[match.CandidateString]
+			results = append(results, ranked{*entry, entry.Score(now) * float64(max(match.Score, 1))})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].rank != results[j].rank {
+			return results[i].rank > results[j].rank
+		}
+
+		return results[i].entry.Path < results[j].entry.Path
+	})
+
+	entries := make([]Entry, 0, len(results))
+	for _, r := range results {
+		entries = append(entries, r.entry)
+	}
+
+	return entries
+}
+
+// Save writes the store back to its file.
+func (s *Store) Save() error {
+	return datafile.Replace(s.filePath, func(w io.Writer) error {
+		for _, entry := range s.Entries(time.Now()) {
+			_, err := fmt.Fprintf(w, "%s\t%d\t%s\n",
+				strconv.FormatFloat(entry.Visits, 'f', -1, 64),
+				entry.LastVisit.Unix(),
+				datafile.Escape(entry.Path),
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) age() {
+	total := 0.0
+	for _, entry := range s.entries {
+		total += entry.Visits
+	}
+
+	if total <= MaxTotalVisits {
+		return
+	}
+
+	for path, entry := range s.entries {
+		entry.Visits *= agingFactor
+		if entry.Visits < 1 {
+			delete(s.entries, path)
+		}
+	}
+}
+
+// Update loads the store kept in filePath, calls fn with it and saves it again
+// if fn doesn't return an error. Other instances can't change the file in
+// between.
+func Update(filePath string, fn func(store *Store) error) error {
+	return datafile.WithLock(filePath, true, func() error {
+		store, err := Load(filePath)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(store); err != nil {
+			return err
+		}
+
+		return store.Save()
+	})
+}
+
+// Record records a visit to each of the given paths in the store kept in
+// filePath.
+func Record(filePath string, now time.Time, paths ...string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	return Update(filePath, func(store *Store) error {
+		for _, path := range paths {
+			store.Visit(path, now)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,155 @@
+package frecency
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScore(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+
+	data := []struct {
+		Age  time.Duration
+		Want float64
+	}{
+		{time.Minute, 40},
+		{2 * time.Hour, 20},
+		{3 * 24 * time.Hour, 5},
+		{30 * 24 * time.Hour, 2.5},
+	}
+
+	for _, tt := range data {
+		entry := Entry{Path: "/a", Visits: 10, LastVisit: now.Add(-tt.Age)}
+		if got := entry.Score(now); got != tt.Want {
+			t.Errorf("age=%v: want=%v, got=%v", tt.Age, tt.Want, got)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	store := &Store{entries: make(map[string]*Entry)}
+
+	for range 5 {
+		store.Visit("/home/user/projects/pathsurfer", now.Add(-48*time.Hour))
+	}
+	store.Visit("/home/user/pictures", now)
+	store.Visit("/srv/pathsurfer-old", now.Add(-60*24*time.Hour))
+
+	data := []struct {
+		Query string
+		Want  []string
+	}{
+		{"psurf", []string{"/home/user/projects/pathsurfer", "/srv/pathsurfer-old"}},
+		{"pic", []string{"/home/user/pictures"}},
+		{"projects", []string{"/home/user/projects/pathsurfer"}},
+		{"nothing", []string{}},
+	}
+
+	for _, tt := range data {
+		got := []string{}
+		for _, entry := range store.Query(tt.Query, now) {
+			got = append(got, entry.Path)
+		}
+
+		if !reflect.DeepEqual(got, tt.Want) {
+			t.Errorf("query=%q: want=%v, got=%v", tt.Query, tt.Want, got)
+		}
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.frecency")
+	now := time.Unix(1_000_000, 0)
+
+	err := Record(filePath, now, "/a", "/path with spaces", "/with\ttab\nand newline", "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Entry{
+		{Path: "/a", Visits: 2, LastVisit: now},
+		{Path: "/path with spaces", Visits: 1, LastVisit: now},
+		{Path: "/with\ttab\nand newline", Visits: 1, LastVisit: now},
+	}
+	if got := store.Entries(now); !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%+v, got=%+v", want, got)
+	}
+}
+
+func TestLoadSkipsInvalidLines(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.frecency")
+	data := "no tabs\n2\t1000000\t/a\nmany\t1000000\t/b\n1\tthen\t/c\n1\t1000000\t/bad\\x\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1_000_000, 0)
+	if err := Record(filePath, now, "/d"); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Entry{
+		{Path: "/a", Visits: 2, LastVisit: now},
+		{Path: "/d", Visits: 1, LastVisit: now},
+	}
+	if got := store.Entries(now); !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%+v, got=%+v", want, got)
+	}
+}
+
+func TestRecordConcurrently(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.frecency")
+	now := time.Unix(1_000_000, 0)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Record(filePath, now, fmt.Sprintf("/%d", i)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	store, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(store.Entries(now)); got != 20 {
+		t.Errorf("want every visit to be kept, got %v entries", got)
+	}
+}
+
+func TestAging(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	store := &Store{entries: make(map[string]*Entry)}
+
+	store.entries["/old"] = &Entry{Path: "/old", Visits: 1, LastVisit: now}
+	store.entries["/busy"] = &Entry{Path: "/busy", Visits: MaxTotalVisits, LastVisit: now}
+	store.Visit("/busy", now)
+
+	if _, ok := store.entries["/old"]; ok {
+		t.Errorf("want /old to be dropped after aging")
+	}
+	if got, want := store.entries["/busy"].Visits, (MaxTotalVisits+1)*agingFactor; got != want {
+		t.Errorf("want=%v, got=%v", want, got)
+	}
+}
@@ -1,7 +1,10 @@
 package fuzzy
 
 import (
+	"cmp"
+	"slices"
 	"sort"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -17,8 +20,15 @@ const (
 	SeparatorBonus   = 10
 	CamelCaseBonus   = 8
 	ConsecutiveBonus = 5
+
+	// Used by FindPaths.
+	BaseNameBonus = 3
+	DepthPenalty  = 2
 )
 
+// Find returns the candidates that contain every rune of rawPattern in order,
+// ignoring case, best matches first. Indexes holds the byte offsets of the
+// matched runes. Matches with the same score keep the order of candidates.
 func Find(rawPattern string, candidates []string) []Match {
 	if len(rawPattern) == 0 || len(candidates) == 0 {
 		return []Match{}
@@ -110,13 +120,56 @@ func Find(rawPattern string, candidates []string) []Match {
 		result = append(result, match)
 	}
 
-	sort.Slice(result, func(x, y int) bool {
+	sort.SliceStable(result, func(x, y int) bool {
 		return result[x].Score > result[y].Score
 	})
 
 	return result
 }
 
+// FindPaths is like Find, but it's meant for slash-separated paths. Matches in
+// the last component of a path are worth more and deeper paths are ranked
+// lower. Matches with the same score are sorted by length and then
+// alphabetically so that the order doesn't depend on the order of candidates.
+func FindPaths(rawPattern string, candidates []string) []Match {
+	result := Find(rawPattern, candidates)
+
+	for i := range result {
+		match := &result[i]
+		baseNameIdx := strings.LastIndex(match.CandidateString, "/") + 1
+
+		for _, idx := range match.Indexes {
+			if idx == 0 {
+				// The start of a path is the start of a component, just like
+				// the character after a separator.
+				match.Score += SeparatorBonus - FirstCharBonus
+			}
+			if idx >= baseNameIdx {
+				match.Score += BaseNameBonus
+			}
+		}
+
+		match.Score -= strings.Count(match.CandidateString, "/") * DepthPenalty
+	}
+
+	slices.SortFunc(result, ComparePathMatches)
+
+	return result
+}
+
+// ComparePathMatches orders matches the way FindPaths does, the best first.
+// It's meant for merging the matches of candidates that come in bit by bit.
+func ComparePathMatches(a, b Match) int {
+	if a.Score != b.Score {
+		return cmp.Compare(b.Score, a.Score)
+	}
+	if len(a.CandidateString) != len(b.CandidateString) {
+		return cmp.Compare(len(a.CandidateString), len(b.CandidateString))
+	}
+
+	return strings.Compare(a.CandidateString, b.CandidateString)
+}
+
 func equalsIgnoreCase(searchChar, targetChar rune) bool {
 	if searchChar == targetChar {
 		return true
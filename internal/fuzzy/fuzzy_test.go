@@ -41,3 +41,48 @@ func TestGeneral(t *testing.T) {
 		}
 	}
 }
+
+func TestFindKeepsOrderOfTies(t *testing.T) {
+	candidates := []string{"xa-1", "xa-2", "xa-3", "xa-4", "xa-5", "xa-6", "xa-7", "xa-8"}
+
+	got := []string{}
+	for _, match := range Find("a", candidates) {
+		got = append(got, match.CandidateString)
+	}
+
+	if !reflect.DeepEqual(got, candidates) {
+		t.Errorf("want=%v, got=%v", candidates, got)
+	}
+}
+
+func TestFindPaths(t *testing.T) {
+	data := []struct {
+		Pattern    string
+		Candidates []string
+		Want       []string
+	}{
+		{
+			// Matches in the base name win over matches in the parents.
+			"conf",
+			[]string{"conf/old/main.go", "internal/conf", "cmd/configure.go"},
+			[]string{"internal/conf", "cmd/configure.go", "conf/old/main.go"},
+		},
+		{
+			// Shallow paths win over deep ones.
+			"main",
+			[]string{"a/b/c/main.go", "main.go", "cmd/main.go"},
+			[]string{"main.go", "cmd/main.go", "a/b/c/main.go"},
+		},
+	}
+
+	for _, tt := range data {
+		got := []string{}
+		for _, match := range FindPaths(tt.Pattern, tt.Candidates) {
+			got = append(got, match.CandidateString)
+		}
+
+		if !reflect.DeepEqual(got, tt.Want) {
+			t.Errorf("pattern=%q: want=%q, got=%q", tt.Pattern, tt.Want, got)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package gitstatus reads the state of a git work tree by running git status.
+package gitstatus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileStatus is a set of flags describing a path in the work tree.
+type FileStatus int
+
+const (
+	Staged FileStatus = 1 << iota
+	Modified
+	Untracked
+	Ignored
+	Conflicted
+)
+
+// Status is the state of a work tree. Paths in Files are relative to Root and
+// use forward slashes. Directories that git reports as a whole, such as an
+// untracked directory, are in Files as well.
+type Status struct {
+	Root string
+
+	// Branch is empty if HEAD is detached, in which case Commit holds the
+	// commit HEAD points to. Ahead and Behind are only set if the branch has
+	// an upstream.
+	Branch      string
+	Commit      string
+	HasUpstream bool
+	Ahead       int
+	Behind      int
+
+	Files map[string]FileStatus
+
+	// dirs holds the combined status of everything below each directory.
+	dirs map[string]FileStatus
+}
+
+// FindRoot returns the top of the work tree that contains dir by looking for a
+// .git directory or file in dir and above it.
+func FindRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// Load runs git status in the work tree whose top is root.
+func Load(ctx context.Context, root string) (Status, error) {
+	cmd := exec.CommandContext(
+		ctx,
+		"git", "-C", root,
+		"status", "--porcelain=v2", "--branch", "-z",
+		"--untracked-files=normal", "--ignored=matching",
+	)
+	// Keeps git from taking the index lock just to refresh it, which could
+	// get in the way of git commands run by the user at the same time.
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0")
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return Status{}, fmt.Errorf("running git status: %w: %s", err, msg)
+		}
+		return Status{}, fmt.Errorf("running git status: %w", err)
+	}
+
+	result, err := Parse(out)
+	result.Root = root
+
+	return result, err
+}
+
+// Parse reads the output of git status --porcelain=v2 --branch -z.
+func Parse(out []byte) (Status, error) {
+	result := Status{
+		Files: make(map[string]FileStatus),
+		dirs:  make(map[string]FileStatus),
+	}
+
+	records := strings.Split(string(out), "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if record == "" {
+			continue
+		}
+
+		var path string
+		var status FileStatus
+
+		switch record[0] {
+		case '#':
+			if err := result.parseHeader(record); err != nil {
+				return result, err
+			}
+			continue
+
+		case '1':
+			// 1 XY sub mH mI mW hH hI path
+			fields := strings.SplitN(record, " ", 9)
+			if len(fields) != 9 {
+				return result, fmt.Errorf("parsing git status: invalid entry %q", record)
+			}
+			path, status = fields[8], parseXY(fields[1])
+
+		case '2':
+			// 2 XY sub mH mI mW hH hI score path, followed by the original
+			// path in a record of its own.
+			fields := strings.SplitN(record, " ", 10)
+			if len(fields) != 10 {
+				return result, fmt.Errorf("parsing git status: invalid entry %q", record)
+			}
+			path, status = fields[9], parseXY(fields[1])
+			i++
+
+		case 'u':
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			fields := strings.SplitN(record, " ", 11)
+			if len(fields) != 11 {
+				return result, fmt.Errorf("parsing git status: invalid entry %q", record)
+			}
+			path, status = fields[10], Conflicted
+
+		case '?':
+			path, status = record[2:], Untracked
+
+		case '!':
+			path, status = record[2:], Ignored
+
+		default:
+			return result, fmt.Errorf("parsing git status: invalid entry %q", record)
+		}
+
+		path = strings.TrimSuffix(path, "/")
+		result.Files[path] |= status
+
+		if status != Ignored {
+			for dir := parentOf(path); dir != ""; dir = parentOf(dir) {
+				result.dirs[dir] |= status
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Status) parseHeader(record string) error {
+	key, value, _ := strings.Cut(strings.TrimPrefix(record, "# "), " ")
+
+	switch key {
+	case "branch.oid":
+		s.Commit = value
+	case "branch.head":
+		if value != "(detached)" {
+			s.Branch = value
+		}
+	case "branch.upstream":
+		s.HasUpstream = true
+	case "branch.ab":
+		rawAhead, rawBehind, _ := strings.Cut(value, " ")
+
+		ahead, err := strconv.Atoi(strings.TrimPrefix(rawAhead, "+"))
+		if err != nil {
+			return fmt.Errorf("parsing git status: invalid branch.ab %q", value)
+		}
+		behind, err := strconv.Atoi(strings.TrimPrefix(rawBehind, "-"))
+		if err != nil {
+			return fmt.Errorf("parsing git status: invalid branch.ab %q", value)
+		}
+
+		s.Ahead, s.Behind = ahead, behind
+	}
+
+	return nil
+}
+
+// parseXY reads the two-letter status of a changed entry. X is the status in
+// the index and Y the one in the work tree; "." means unchanged.
+func parseXY(xy string) FileStatus {
+	result := FileStatus(0)
+	if len(xy) != 2 {
+		return result
+	}
+
+	if xy[0] != '.' {
+		result |= Staged
+	}
+	if xy[1] != '.' {
+		result |= Modified
+	}
+
+	return result
+}
+
+func parentOf(path string) string {
+	idx := strings.LastIndexByte(path, '/')
+	if idx == -1 {
+		return ""
+	}
+
+	return path[:idx]
+}
+
+// For returns the status of the file or directory at path, which must be
+// absolute. A directory gets the combined status of everything below it. Paths
+// below an untracked or ignored directory are untracked or ignored as well.
+func (s Status) For(path string, isDir bool) FileStatus {
+	if s.Root == "" {
+		return 0
+	}
+
+	rel, err := filepath.Rel(s.Root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return 0
+	}
+	rel = filepath.ToSlash(rel)
+
+	result := s.Files[rel]
+	if isDir {
+		result |= s.dirs[rel]
+	}
+	if result != 0 {
+		return result
+	}
+
+	for dir := parentOf(rel); dir != ""; dir = parentOf(dir) {
+		if status := s.Files[dir] & (Untracked | Ignored); status != 0 {
+			return status
+		}
+	}
+
+	return 0
+}
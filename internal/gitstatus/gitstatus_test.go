@@ -0,0 +1,133 @@
+package gitstatus
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	out := strings.Join([]string{
+		"# branch.oid 1234567890abcdef",
+		"# branch.head main",
+		"# branch.upstream origin/main",
+		"# branch.ab +2 -1",
+		"1 .M N... 100644 100644 100644 aaa aaa src/main.go",
+		"1 A. N... 000000 100644 100644 000 bbb src/new file.go",
+		"2 R. N... 100644 100644 100644 ccc ccc R100 docs/renamed.md",
+		"docs/old.md",
+		"u UU N... 100644 100644 100644 100644 ddd eee fff conflict.txt",
+		"? scratch/",
+		"! build/",
+		"",
+	}, "\x00")
+
+	got, err := Parse([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Root = "/repo"
+
+	if got.Branch != "main" || !got.HasUpstream || got.Ahead != 2 || got.Behind != 1 {
+		t.Errorf("unexpected branch info: %+v", got)
+	}
+
+	data := []struct {
+		Path  string
+		IsDir bool
+		Want  FileStatus
+	}{
+		{"/repo/src/main.go", false, Modified},
+		{"/repo/src/new file.go", false, Staged},
+		{"/repo/src", true, Modified | Staged},
+		{"/repo/docs/renamed.md", false, Staged},
+		{"/repo/docs/old.md", false, 0},
+		{"/repo/conflict.txt", false, Conflicted},
+		{"/repo/scratch", true, Untracked},
+		{"/repo/scratch/deep/a.txt", false, Untracked},
+		{"/repo/build/out.bin", false, Ignored},
+		{"/repo/README.md", false, 0},
+		{"/repo", true, 0},
+		{"/elsewhere/src/main.go", false, 0},
+	}
+
+	for _, tt := range data {
+		if status := got.For(tt.Path, tt.IsDir); status != tt.Want {
+			t.Errorf("path=%q: want=%v, got=%v", tt.Path, tt.Want, status)
+		}
+	}
+}
+
+func TestParseDetached(t *testing.T) {
+	got, err := Parse([]byte("# branch.oid abcdef123456\x00# branch.head (detached)\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Branch != "" || got.Commit != "abcdef123456" || got.HasUpstream {
+		t.Errorf("unexpected branch info: %+v", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	root := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+
+		cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, contents string) {
+		t.Helper()
+
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q", "-b", "trunk")
+	write(".gitignore", "*.log\n")
+	write("tracked.txt", "one")
+	git("add", ".")
+	git("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+
+	write("tracked.txt", "two")
+	write("sub/new.txt", "")
+	write("debug.log", "")
+
+	found, ok := FindRoot(filepath.Join(root, "sub"))
+	if !ok || found != root {
+		t.Fatalf("want root=%q, got %q", root, found)
+	}
+
+	got, err := Load(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Branch != "trunk" {
+		t.Errorf("want branch=trunk, got %q", got.Branch)
+	}
+	if status := got.For(filepath.Join(root, "tracked.txt"), false); status != Modified {
+		t.Errorf("want tracked.txt to be modified, got %v", status)
+	}
+	if status := got.For(filepath.Join(root, "sub", "new.txt"), false); status != Untracked {
+		t.Errorf("want sub/new.txt to be untracked, got %v", status)
+	}
+	if status := got.For(filepath.Join(root, "debug.log"), false); status != Ignored {
+		t.Errorf("want debug.log to be ignored, got %v", status)
+	}
+}
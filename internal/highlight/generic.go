@@ -0,0 +1,245 @@
+package highlight
+
+import (
+	"slices"
+	"strings"
+)
+
+// Generic is a lexer for languages that are made up of identifiers, numbers,
+// strings and comments, which covers most programming languages.
+type Generic struct {
+	Keywords []string
+	Types    []string
+
+	// LineComments are the prefixes of comments that run until the end of the
+	// line, e.g. "//". If CommentNeedsSpace is set, they only count at the
+	// beginning of a line or after whitespace, like "#" in shell scripts.
+	LineComments      []string
+	CommentNeedsSpace bool
+
+	// BlockComment holds the start and the end of block comments, e.g. "/*"
+	// and "*/".
+	BlockComment [2]string
+
+	// Quotes holds the characters that start and end strings on a single line.
+	// A backslash escapes the next character. MultiLineQuotes are delimiters
+	// of strings that can span several lines, such as `"""` in Python, and
+	// RawQuotes are the same but without escapes, such as "`" in Go.
+	Quotes          string
+	MultiLineQuotes []string
+	RawQuotes       []string
+
+	// Variables highlights $name, ${name} and $? the way shells expand them.
+	Variables bool
+
+	// Keys highlights strings that are followed by a colon as keys, like in
+	// JSON objects.
+	Keys bool
+}
+
+// genericState is what carries over from one line to the next.
+type genericState struct {
+	inComment bool
+
+	// closeString is the delimiter of the string that's still open, if any.
+	closeString string
+	raw         bool
+}
+
+func (g *Generic) Tokenize(lines []string) [][]Token {
+	result := make([][]Token, 0, len(lines))
+	state := genericState{}
+
+	for _, line := range lines {
+		result = append(result, g.tokenizeLine(line, &state))
+	}
+
+	return result
+}
+
+func (g *Generic) tokenizeLine(line string, state *genericState) []Token {
+	b := lineBuilder{}
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+
+		if state.inComment {
+			end := strings.Index(rest, g.BlockComment[1])
+			if end == -1 {
+				end = len(rest)
+			} else {
+				end += len(g.BlockComment[1])
+				state.inComment = false
+			}
+
+			b.add(KindComment, rest[:end])
+			i += end
+			continue
+		}
+
+		if state.closeString != "" {
+			end, closed := findClose(rest, state.closeString, !state.raw)
+			if closed {
+				state.closeString = ""
+			}
+
+			b.add(KindString, rest[:end])
+			i += end
+			continue
+		}
+
+		if g.startsLineComment(line, i) {
+			b.add(KindComment, rest)
+			break
+		}
+
+		if start := g.BlockComment[0]; start != "" && strings.HasPrefix(rest, start) {
+			b.add(KindComment, start)
+			i += len(start)
+			state.inComment = true
+			continue
+		}
+
+		if quote, raw := g.multiLineQuote(rest); quote != "" {
+			b.add(KindString, quote)
+			i += len(quote)
+			state.closeString, state.raw = quote, raw
+			continue
+		}
+
+		c := line[i]
+		switch {
+		case strings.IndexByte(g.Quotes, c) != -1:
+			end, _ := findClose(rest[1:], rest[:1], true)
+			text := rest[:1+end]
+			i += len(text)
+
+			kind := KindString
+			if g.Keys && strings.HasPrefix(strings.TrimLeft(line[i:], " \t"), ":") {
+				kind = KindKey
+			}
+			b.add(kind, text)
+
+		case g.Variables && c == '$':
+			n := variableLen(rest)
+			b.add(KindVariable, rest[:n])
+			i += n
+
+		case isDigit(c) && (i == 0 || !isIdentByte(line[i-1])):
+			n := 1
+			for n < len(rest) && (isIdentByte(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			b.add(KindNumber, rest[:n])
+			i += n
+
+		case isIdentByte(c):
+			n := 1
+			for n < len(rest) && isIdentByte(rest[n]) {
+				n++
+			}
+
+			word := rest[:n]
+			switch {
+			case slices.Contains(g.Keywords, word):
+				b.add(KindKeyword, word)
+			case slices.Contains(g.Types, word):
+				b.add(KindType, word)
+			default:
+				b.add(KindPlain, word)
+			}
+			i += n
+
+		default:
+			b.add(KindPlain, rest[:1])
+			i++
+		}
+	}
+
+	return b.tokens
+}
+
+func (g *Generic) startsLineComment(line string, i int) bool {
+	for _, prefix := range g.LineComments {
+		if !strings.HasPrefix(line[i:], prefix) {
+			continue
+		}
+
+		if !g.CommentNeedsSpace || i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *Generic) multiLineQuote(s string) (quote string, raw bool) {
+	for _, q := range g.MultiLineQuotes {
+		if strings.HasPrefix(s, q) {
+			return q, false
+		}
+	}
+	for _, q := range g.RawQuotes {
+		if strings.HasPrefix(s, q) {
+			return q, true
+		}
+	}
+
+	return "", false
+}
+
+// findClose returns the index right after the first delim in s and true, or
+// the length of s and false if there's no delim in it. With escapes set, a
+// backslash hides the character after it.
+func findClose(s, delim string, escapes bool) (int, bool) {
+	for i := 0; i < len(s); i++ {
+		if escapes && s[i] == '\\' {
+			i++
+			continue
+		}
+
+		if strings.HasPrefix(s[i:], delim) {
+			return i + len(delim), true
+		}
+	}
+
+	return len(s), false
+}
+
+// variableLen returns the length of the shell variable at the beginning of s,
+// which starts with a '$'.
+func variableLen(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+
+	switch c := s[1]; {
+	case c == '{':
+		if end := strings.IndexByte(s, '}'); end != -1 {
+			return end + 1
+		}
+		return len(s)
+
+	case isDigit(c) || strings.IndexByte("#?@*$!-", c) != -1:
+		return 2
+
+	case isIdentByte(c):
+		n := 2
+		for n < len(s) && isIdentByte(s[n]) {
+			n++
+		}
+		return n
+	}
+
+	return 1
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// isIdentByte reports whether c can be part of an identifier. Bytes of
+// non-ASCII characters count as well so that they're never split up.
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c >= 0x80
+}
@@ -0,0 +1,105 @@
+// Package highlight splits source code into tokens so that it can be shown in
+// colour. Lexers are looked up by file name and more of them can be added with
+// Register.
+package highlight
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+type Kind int
+
+const (
+	KindPlain Kind = iota
+	KindKeyword
+	KindType
+	KindString
+	KindNumber
+	KindComment
+	KindKey
+	KindVariable
+	KindHeading
+)
+
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Lexer splits lines into tokens. Tokenize gets the beginning of a file, so
+// state such as an unterminated block comment carries over from one line to
+// the next. It returns one slice of tokens per line, and the text of each line's
+// tokens adds up to the line itself.
+type Lexer interface {
+	Tokenize(lines []string) [][]Token
+}
+
+type registration struct {
+	patterns []string
+	lexer    Lexer
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registration
+)
+
+// Register makes lexer handle files whose base names match one of patterns,
+// see filepath.Match. Lexers registered later take precedence, so a built-in
+// lexer can be replaced by registering another one for the same patterns.
+func Register(lexer Lexer, patterns ...string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry = append(registry, registration{patterns: patterns, lexer: lexer})
+}
+
+// ForFile returns the lexer for the file at path, or nil if there isn't one.
+func ForFile(path string) Lexer {
+	name := filepath.Base(path)
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, r := range slices.Backward(registry) {
+		for _, pattern := range r.patterns {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return r.lexer
+			}
+		}
+	}
+
+	return nil
+}
+
+// Highlight tokenizes lines with the lexer for the file at path. It returns nil
+// if there's no lexer for it.
+func Highlight(path string, lines []string) [][]Token {
+	lexer := ForFile(path)
+	if lexer == nil {
+		return nil
+	}
+
+	return lexer.Tokenize(lines)
+}
+
+// lineBuilder collects the tokens of a single line and merges neighbouring
+// tokens of the same kind.
+type lineBuilder struct {
+	tokens []Token
+}
+
+func (b *lineBuilder) add(kind Kind, text string) {
+	if text == "" {
+		return
+	}
+
+	if n := len(b.tokens); n > 0 && b.tokens[n-1].Kind == kind {
+		b.tokens[n-1].Text += text
+		return
+	}
+
+	b.tokens = append(b.tokens, Token{Kind: kind, Text: text})
+}
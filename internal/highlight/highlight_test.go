@@ -0,0 +1,179 @@
+package highlight
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// render writes every token that isn't plain text as <kind:text> so that
+// expectations stay readable.
+func render(lines [][]Token) []string {
+	names := map[Kind]string{
+		KindKeyword:  "kw",
+		KindType:     "type",
+		KindString:   "str",
+		KindNumber:   "num",
+		KindComment:  "com",
+		KindKey:      "key",
+		KindVariable: "var",
+		KindHeading:  "h",
+	}
+
+	result := []string{}
+	for _, tokens := range lines {
+		var sb strings.Builder
+		for _, token := range tokens {
+			if token.Kind == KindPlain {
+				sb.WriteString(token.Text)
+			} else {
+				sb.WriteString("<" + names[token.Kind] + ":" + token.Text + ">")
+			}
+		}
+		result = append(result, sb.String())
+	}
+
+	return result
+}
+
+func TestHighlight(t *testing.T) {
+	data := []struct {
+		Path  string
+		Lines []string
+		Want  []string
+	}{
+		{
+			"main.go",
+			[]string{
+				`func f(x int) string { // done`,
+				"\treturn `raw",
+				"still raw` + \"a\\\"b\" /* c",
+				`*/ + 'x' + 0x1F`,
+			},
+			[]string{
+				`<kw:func> f(x <type:int>) <type:string> { <com:// done>`,
+				"\t<kw:return> <str:`raw>",
+				"<str:still raw`> + <str:\"a\\\"b\"> <com:/* c>",
+				`<com:*/> + <str:'x'> + <num:0x1F>`,
+			},
+		},
+		{
+			"run.sh",
+			[]string{
+				`if [ "$1" = x#y ]; then # check`,
+				`  echo ${#args} $HOME_DIR 'it''s'`,
+			},
+			[]string{
+				`<kw:if> [ <str:"$1"> = x#y ]; <kw:then> <com:# check>`,
+				`  echo <var:${#args}> <var:$HOME_DIR> <str:'it''s'>`,
+			},
+		},
+		{
+			"package.json",
+			[]string{`{"name": "x", "n" : 1.5, "ok": [true, null]}`},
+			[]string{`{<key:"name">: <str:"x">, <key:"n"> : <num:1.5>, <key:"ok">: [<kw:true>, <kw:null>]}`},
+		},
+		{
+			"config.yaml",
+			[]string{
+				"---",
+				"# comment",
+				"name: app # trailing",
+				"- url: http://example.com:80",
+				`  "quoted key": 'value'`,
+				"  enabled: true",
+			},
+			[]string{
+				"<kw:--->",
+				"<com:# comment>",
+				"<key:name>: app <com:# trailing>",
+				"- <key:url>: http://example.com:<num:80>",
+				`  <key:"quoted key">: <str:'value'>`,
+				"  <key:enabled>: <kw:true>",
+			},
+		},
+		{
+			"README.md",
+			[]string{
+				"# Title",
+				"Use `go build` here.",
+				"- item",
+				"```go",
+				"# not a heading",
+				"```",
+				"> quote",
+			},
+			[]string{
+				"<h:# Title>",
+				"Use <str:`go build`> here.",
+				"<kw:->" + " item",
+				"<com:```go>",
+				"<str:# not a heading>",
+				"<com:```>",
+				"<com:> quote>",
+			},
+		},
+		{
+			"script.py",
+			[]string{
+				`def f(s: str) -> None:`,
+				`    """Doc`,
+				`    string."""`,
+				`    return s # done`,
+			},
+			[]string{
+				`<kw:def> f(s: <type:str>) -> <kw:None>:`,
+				`    <str:"""Doc>`,
+				`<str:    string.""">`,
+				`    <kw:return> s <com:# done>`,
+			},
+		},
+	}
+
+	for _, tt := range data {
+		got := Highlight(tt.Path, tt.Lines)
+		if got := render(got); !reflect.DeepEqual(got, tt.Want) {
+			t.Errorf("path=%q:\nwant=%q\ngot= %q", tt.Path, tt.Want, got)
+		}
+
+		// Tokens always add up to the original lines.
+		for i, tokens := range got {
+			text := ""
+			for _, token := range tokens {
+				text += token.Text
+			}
+			if text != tt.Lines[i] {
+				t.Errorf("path=%q: want=%q, got=%q", tt.Path, tt.Lines[i], text)
+			}
+		}
+	}
+}
+
+type upperLexer struct{}
+
+func (upperLexer) Tokenize(lines []string) [][]Token {
+	result := [][]Token{}
+	for _, line := range lines {
+		result = append(result, []Token{{Kind: KindKeyword, Text: line}})
+	}
+
+	return result
+}
+
+func TestRegister(t *testing.T) {
+	if Highlight("notes.txt", []string{"a"}) != nil {
+		t.Errorf("want no lexer for notes.txt")
+	}
+
+	Register(upperLexer{}, "*.txt", "*.go")
+	defer func() {
+		registry = registry[:len(registry)-1]
+	}()
+
+	for _, path := range []string{"/a/notes.txt", "main.go"} {
+		got := render(Highlight(path, []string{"a"}))
+		if want := []string{"<kw:a>"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("path=%q: want=%q, got=%q", path, want, got)
+		}
+	}
+}
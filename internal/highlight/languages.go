@@ -0,0 +1,60 @@
+package highlight
+
+func init() {
+	Register(&Generic{
+		Keywords: []string{
+			"break", "case", "chan", "const", "continue", "default", "defer", "else",
+			"fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+			"map", "package", "range", "return", "select", "struct", "switch", "type",
+			"var", "true", "false", "nil", "iota",
+		},
+		Types: []string{
+			"any", "bool", "byte", "comparable", "complex64", "complex128", "error",
+			"float32", "float64", "int", "int8", "int16", "int32", "int64", "rune",
+			"string", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		},
+		LineComments: []string{"//"},
+		BlockComment: [2]string{"/*", "*/"},
+		Quotes:       `"'`,
+		RawQuotes:    []string{"`"},
+	}, "*.go")
+
+	Register(&Generic{
+		Keywords: []string{
+			"if", "then", "else", "elif", "fi", "case", "esac", "for", "while", "until",
+			"do", "done", "in", "function", "select", "return", "local", "export",
+			"readonly", "declare", "unset", "exit", "break", "continue", "source",
+			"alias", "eval", "exec", "set", "shift", "trap", "end",
+		},
+		LineComments:      []string{"#"},
+		CommentNeedsSpace: true,
+		Quotes:            "\"'`",
+		Variables:         true,
+	}, "*.sh", "*.bash", "*.zsh", "*.fish", ".bashrc", ".bash_profile", ".zshrc", ".profile")
+
+	Register(&Generic{
+		Keywords: []string{"true", "false", "null"},
+		Quotes:   `"`,
+		Keys:     true,
+	}, "*.json")
+
+	Register(YAML{}, "*.yaml", "*.yml")
+
+	Register(Markdown{}, "*.md", "*.markdown")
+
+	Register(&Generic{
+		Keywords: []string{
+			"and", "as", "assert", "async", "await", "break", "class", "continue",
+			"def", "del", "elif", "else", "except", "finally", "for", "from", "global",
+			"if", "import", "in", "is", "lambda", "nonlocal", "not", "or", "pass",
+			"raise", "return", "try", "while", "with", "yield", "True", "False", "None",
+		},
+		Types: []string{
+			"bool", "bytes", "dict", "float", "frozenset", "int", "list", "object",
+			"set", "str", "tuple", "type",
+		},
+		LineComments:    []string{"#"},
+		Quotes:          `"'`,
+		MultiLineQuotes: []string{`"""`, `'''`},
+	}, "*.py", "*.pyi")
+}
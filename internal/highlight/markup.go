@@ -0,0 +1,130 @@
+package highlight
+
+import (
+	"regexp"
+	"strings"
+)
+
+// YAML highlights keys, comments and scalars in YAML documents.
+type YAML struct{}
+
+var (
+	yamlKey   = regexp.MustCompile(`^(\s*(?:- +)*)("[^"]*"|'[^']*'|[^\s#'"][^#]*?)(:)(\s|$)`)
+	yamlValue = &Generic{
+		Keywords:          []string{"true", "false", "null", "yes", "no", "on", "off"},
+		LineComments:      []string{"#"},
+		CommentNeedsSpace: true,
+		Quotes:            `"'`,
+	}
+)
+
+func (YAML) Tokenize(lines []string) [][]Token {
+	result := make([][]Token, 0, len(lines))
+
+	for _, line := range lines {
+		b := lineBuilder{}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "---" || trimmed == "..." {
+			b.add(KindKeyword, line)
+			result = append(result, b.tokens)
+			continue
+		}
+
+		rest := line
+		if m := yamlKey.FindStringSubmatchIndex(line); m != nil {
+			b.add(KindPlain, line[:m[3]])
+			b.add(KindKey, line[m[4]:m[5]])
+			b.add(KindPlain, line[m[6]:m[7]])
+			rest = line[m[7]:]
+		}
+
+		// Values are scanned on their own, so a "#" right at their beginning
+		// still starts a comment.
+		state := genericState{}
+		for _, token := range yamlValue.tokenizeLine(rest, &state) {
+			b.add(token.Kind, token.Text)
+		}
+
+		result = append(result, b.tokens)
+	}
+
+	return result
+}
+
+// Markdown highlights headings, code blocks and spans, block quotes and list
+// markers.
+type Markdown struct{}
+
+var (
+	markdownHeading    = regexp.MustCompile(`^ {0,3}#{1,6}(\s|$)`)
+	markdownListMarker = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])(\s)`)
+)
+
+func (Markdown) Tokenize(lines []string) [][]Token {
+	result := make([][]Token, 0, len(lines))
+
+	// fence is the marker of the code block that's still open, if any.
+	fence := ""
+
+	for _, line := range lines {
+		b := lineBuilder{}
+		trimmed := strings.TrimLeft(line, " ")
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				b.add(KindComment, line)
+				fence = ""
+			} else {
+				b.add(KindString, line)
+			}
+
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			b.add(KindComment, line)
+
+		case markdownHeading.MatchString(line):
+			b.add(KindHeading, line)
+
+		case strings.HasPrefix(trimmed, ">"):
+			b.add(KindComment, line)
+
+		default:
+			rest := line
+			if m := markdownListMarker.FindStringSubmatchIndex(line); m != nil {
+				b.add(KindPlain, line[:m[3]])
+				b.add(KindKeyword, line[m[4]:m[5]])
+				rest = line[m[5]:]
+			}
+
+			addCodeSpans(&b, rest)
+		}
+
+		result = append(result, b.tokens)
+	}
+
+	return result
+}
+
+// addCodeSpans adds text with the parts between backticks as strings.
+func addCodeSpans(b *lineBuilder, text string) {
+	for text != "" {
+		start := strings.IndexByte(text, '`')
+		if start == -1 {
+			break
+		}
+
+		end := strings.IndexByte(text[start+1:], '`')
+		if end == -1 {
+			break
+		}
+		end += start + 2
+
+		b.add(KindPlain, text[:start])
+		b.add(KindString, text[start:end])
+		text = text[end:]
+	}
+
+	b.add(KindPlain, text)
+}
@@ -0,0 +1,77 @@
+// Package history keeps the directories visited in the navigator so that they
+// can be gone back to, even in later sessions.
+package history
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bnuredini/pathsurfer/internal/datafile"
+)
+
+// Max is how many entries are kept. The oldest ones are dropped first.
+const Max = 100
+
+// Entry is a visited directory along with the name of the entry that was
+// selected in it when it was left. Name is empty if nothing was selected.
+type Entry struct {
+	Path string
+	Name string
+}
+
+// Load reads the entries kept in filePath, oldest first. Each line in the file
+// holds the name of the selected entry, a tab and the path of the directory,
+// both escaped with datafile.Escape. Lines that can't be read are skipped. A
+// missing file means that nothing has been visited yet.
+func Load(filePath string) ([]Entry, error) {
+	result := []Entry{}
+
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields, err := datafile.Fields(line, 2)
+		if err != nil || fields[1] == "" {
+			continue
+		}
+
+		result = append(result, Entry{Path: fields[1], Name: fields[0]})
+	}
+
+	return result, scanner.Err()
+}
+
+// Save replaces the entries kept in filePath with the last Max of entries. The
+// file is locked while it's replaced so that sessions ending at the same time
+// don't write over each other.
+func Save(filePath string, entries []Entry) error {
+	entries = entries[max(len(entries)-Max, 0):]
+
+	return datafile.WithLock(filePath, true, func() error {
+		return datafile.Replace(filePath, func(w io.Writer) error {
+			for _, entry := range entries {
+				if _, err := fmt.Fprintf(w, "%s\t%s\n", datafile.Escape(entry.Name), datafile.Escape(entry.Path)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.history")
+
+	entries, err := Load(filePath)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("want nothing for a missing file, got %+v, %v", entries, err)
+	}
+
+	want := []Entry{
+		{Path: "/a", Name: "b"},
+		{Path: "/a/b", Name: ""},
+		{Path: "/path with spaces", Name: "file with spaces.txt"},
+		{Path: "/with\ttab", Name: "with\nnewline"},
+		{Path: `C:\with\backslashes`, Name: `\t`},
+	}
+	if err := Save(filePath, want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%+v, got=%+v", want, got)
+	}
+}
+
+func TestSaveKeepsTheNewest(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.history")
+
+	entries := []Entry{}
+	for i := range Max + 10 {
+		entries = append(entries, Entry{Path: fmt.Sprintf("/%d", i)})
+	}
+	if err := Save(filePath, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != Max || got[0].Path != "/10" {
+		t.Errorf("want the last %v entries, got %v starting with %+v", Max, len(got), got[0])
+	}
+}
+
+func TestLoadSkipsInvalidLines(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.history")
+	data := "no tab\nb\t/a\nx\t\nbad\\x\t/b\nc\t/c\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Entry{{Path: "/a", Name: "b"}, {Path: "/c", Name: "c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%+v, got=%+v", want, got)
+	}
+}
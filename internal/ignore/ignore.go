@@ -0,0 +1,300 @@
+// Package ignore decides which files are ignored by .gitignore files, .ignore
+// files and a global ignore file, using the same rules as git.
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileNames are the names of the ignore files read in every directory, in
+// order of increasing precedence. Like in ripgrep, .gitignore files are only
+// used inside git repositories while .ignore files are used everywhere.
+var FileNames = []string{".gitignore", ".ignore"}
+
+// Matcher reads ignore files as they're needed and caches them. It's safe for
+// concurrent use.
+//
+// For a given path, the rules of the global ignore file come first, followed
+// by the rules of the ignore files in every directory from the top of the git
+// repository (or the file system root outside of repositories) down to the
+// path's directory. The last rule that matches decides whether the path is
+// ignored.
+//
+// Only the path itself is matched against the rules. Callers that walk a tree
+// shouldn't descend into ignored directories, which means that files inside
+// them are ignored as well, just like with git.
+type Matcher struct {
+	global []rule
+
+	mu   sync.Mutex
+	dirs map[string]dirRules
+}
+
+// dirRules holds the rules that apply to the entries of a directory and the
+// top directory that the global rules are relative to.
+type dirRules struct {
+	rules []rule
+	top   string
+}
+
+type rule struct {
+	// The directory the pattern is relative to. It's empty for the rules of
+	// the global ignore file, which are relative to the top directory.
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// New returns a matcher that uses the global ignore file at globalFile in
+// addition to the ignore files found next to the paths it's asked about.
+// globalFile can be empty and it doesn't have to exist. The matcher can be used
+// even if an error is returned, it just won't use the global ignore file.
+func New(globalFile string) (*Matcher, error) {
+	m := &Matcher{dirs: make(map[string]dirRules)}
+
+	if globalFile == "" {
+		return m, nil
+	}
+
+	global, err := readFile(globalFile, "")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return m, err
+	}
+	m.global = global
+
+	return m, nil
+}
+
+// Ignored reports whether the file at path, which has to be absolute, is
+// ignored.
+func (m *Matcher) Ignored(filePath string, isDir bool) bool {
+	filePath = filepath.Clean(filePath)
+	if filepath.Base(filePath) == ".git" {
+		return true
+	}
+
+	dir := filepath.Dir(filePath)
+	if dir == filePath {
+		return false
+	}
+
+	m.mu.Lock()
+	d := m.rulesFor(dir)
+	m.mu.Unlock()
+
+	ignored := false
+	for _, r := range d.rules {
+		base := r.base
+		if base == "" {
+			base = d.top
+		}
+
+		rel, err := filepath.Rel(base, filePath)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		if r.matches(filepath.ToSlash(rel), isDir) {
+			ignored = !r.negate
+		}
+	}
+
+	return ignored
+}
+
+// Reset forgets every ignore file read so far except for the global one so
+// that changes to them are picked up.
+func (m *Matcher) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.dirs = make(map[string]dirRules)
+}
+
+// rulesFor returns the rules that apply to the entries of dir. m.mu must be
+// held.
+func (m *Matcher) rulesFor(dir string) dirRules {
+	if cached, ok := m.dirs[dir]; ok {
+		return cached
+	}
+
+	chain := []string{}
+	insideRepo := false
+
+	for d := dir; ; d = filepath.Dir(d) {
+		chain = append(chain, d)
+
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			insideRepo = true
+			break
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	result := append([]rule{}, m.global...)
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, name := range FileNames {
+			if name == ".gitignore" && !insideRepo {
+				continue
+			}
+
+			// Unreadable ignore files are treated like missing ones.
+			fileRules, _ := readFile(filepath.Join(chain[i], name), chain[i])
+			result = append(result, fileRules...)
+		}
+	}
+
+	d := dirRules{rules: result, top: chain[len(chain)-1]}
+	m.dirs[dir] = d
+
+	return d
+}
+
+func readFile(filePath, base string) ([]rule, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parse(f, base)
+}
+
+func parse(r io.Reader, base string) ([]rule, error) {
+	result := []rule{}
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		if rule, ok := parseLine(scanner.Text(), base); ok {
+			result = append(result, rule)
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+// parseLine parses a single line of an ignore file. See gitignore(5) for the
+// format.
+func parseLine(line, base string) (rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	// Trailing spaces are ignored unless they're escaped with a backslash.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+
+	r := rule{base: base}
+
+	switch {
+	case strings.HasPrefix(line, "!"):
+		r.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash at the beginning or in the middle anchors the pattern to the
+	// directory of the ignore file. Otherwise, it matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule{}, false
+	}
+
+	for _, segment := range strings.Split(line, "/") {
+		if segment != "**" {
+			segment = convertNegatedClasses(segment)
+		}
+		r.segments = append(r.segments, segment)
+	}
+	if !anchored {
+		r.segments = append([]string{"**"}, r.segments...)
+	}
+
+	return r, true
+}
+
+// convertNegatedClasses turns character classes like [!a-z], which is how
+// they're written in ignore files, into [^a-z], which is what path.Match
+// expects.
+func convertNegatedClasses(segment string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		sb.WriteByte(c)
+
+		switch {
+		case c == '\\' && i+1 < len(segment):
+			i++
+			sb.WriteByte(segment[i])
+		case c == '[' && i+1 < len(segment) && segment[i+1] == '!':
+			i++
+			sb.WriteByte('^')
+		}
+	}
+
+	return sb.String()
+}
+
+// matches reports whether rel, a slash-separated path relative to the base of
+// r, matches the pattern of r.
+func (r rule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+
+			// A trailing "**" matches everything inside, but not the
+			// directory itself.
+			if len(rest) == 0 {
+				return len(segments) > 0
+			}
+
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(rest, segments[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+
+	return len(segments) == 0
+}
@@ -0,0 +1,156 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRuleMatches(t *testing.T) {
+	data := []struct {
+		Pattern string
+		Path    string
+		IsDir   bool
+		Want    bool
+	}{
+		{"*.log", "debug.log", false, true},
+		{"*.log", "logs/debug.log", false, true},
+		{"*.log", "debug.log.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"/build", "build", false, true},
+		{"/build", "src/build", false, false},
+		{"doc/frotz", "doc/frotz", true, true},
+		{"doc/frotz", "a/doc/frotz", true, false},
+		{"**/foo", "foo", false, true},
+		{"**/foo", "a/b/foo", false, true},
+		{"foo/**", "foo", true, false},
+		{"foo/**", "foo/a/b", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "a/x/y/c", false, false},
+		{"file?.txt", "file1.txt", false, true},
+		{"file[!0-9].txt", "file1.txt", false, false},
+		{"file[!0-9].txt", "fileA.txt", false, true},
+		{`\#notes`, "#notes", false, true},
+		{`\!important`, "!important", false, true},
+		{"trailing   ", "trailing", false, true},
+		{`space\ `, "space ", false, true},
+	}
+
+	for _, tt := range data {
+		r, ok := parseLine(tt.Pattern, "")
+		if !ok {
+			t.Errorf("pattern=%q: want a rule", tt.Pattern)
+			continue
+		}
+
+		if got := r.matches(tt.Path, tt.IsDir); got != tt.Want {
+			t.Errorf("pattern=%q, path=%q, isDir=%v: want=%v, got=%v", tt.Pattern, tt.Path, tt.IsDir, tt.Want, got)
+		}
+	}
+
+	for _, line := range []string{"", "# comment", "   ", "/", "!"} {
+		if _, ok := parseLine(line, ""); ok {
+			t.Errorf("line=%q: want no rule", line)
+		}
+	}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, contents := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+
+	writeFiles(t, root, map[string]string{
+		"global":                 "*.swp\n",
+		"repo/.git/":             "",
+		"repo/.gitignore":        "node_modules/\n*.log\n!keep.log\n/target\n",
+		"repo/.ignore":           "secret.txt\n",
+		"repo/sub/.gitignore":    "!important.log\nlocal/\n",
+		"repo/sub/deeper/x.txt":  "",
+		"outside/.gitignore":     "*.txt\n",
+		"outside/.ignore":        "*.md\n",
+		"outside/inside/a.md":    "",
+		"repo/node_modules/pkg/": "",
+	})
+
+	m, err := New(filepath.Join(root, "global"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		Path  string
+		IsDir bool
+		Want  bool
+	}{
+		{"repo/node_modules", true, true},
+		{"repo/sub/node_modules", true, true},
+		{"repo/node_modules", false, false},
+		{"repo/debug.log", false, true},
+		{"repo/keep.log", false, false},
+		{"repo/sub/important.log", false, false},
+		{"repo/sub/other.log", false, true},
+		{"repo/target", true, true},
+		{"repo/sub/target", true, false},
+		{"repo/secret.txt", false, true},
+		{"repo/sub/local", true, true},
+		{"repo/local", true, false},
+		{"repo/main.go", false, false},
+		{"repo/main.go.swp", false, true},
+		{"repo/.git", true, true},
+		{"outside/notes.txt", false, false},
+		{"outside/notes.md", false, true},
+		{"outside/inside/a.md", false, true},
+	}
+
+	for _, tt := range data {
+		p := filepath.Join(root, filepath.FromSlash(tt.Path))
+		if got := m.Ignored(p, tt.IsDir); got != tt.Want {
+			t.Errorf("path=%q, isDir=%v: want=%v, got=%v", tt.Path, tt.IsDir, tt.Want, got)
+		}
+	}
+
+	// Changes are only picked up after a reset.
+	writeFiles(t, root, map[string]string{"repo/.ignore": "main.go\n"})
+	if m.Ignored(filepath.Join(repo, "main.go"), false) {
+		t.Errorf("want the cached rules to be used before a reset")
+	}
+
+	m.Reset()
+	if !m.Ignored(filepath.Join(repo, "main.go"), false) {
+		t.Errorf("want the new rules to be used after a reset")
+	}
+}
+
+func TestNewWithoutGlobalFile(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("want no error for a missing global file, got %v", err)
+	}
+	if _, err := New(t.TempDir()); err == nil {
+		t.Errorf("want an error for a global file that can't be read")
+	}
+}
@@ -0,0 +1,52 @@
+package marks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// ParseKey returns the mark for s, which has to be a single character.
+func ParseKey(s string) (rune, error) {
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("%q is not a valid mark", s)
+	}
+
+	key, _ := utf8.DecodeRuneInString(s)
+	return key, nil
+}
+
+// EncodeJSON writes marks as a JSON object that maps each mark to its path.
+// The keys are sorted.
+func EncodeJSON(w io.Writer, marks map[rune]string) error {
+	object := make(map[string]string, len(marks))
+	for key, path := range marks {
+		object[string(key)] = path
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+
+	return enc.Encode(object)
+}
+
+// DecodeJSON reads marks written by EncodeJSON.
+func DecodeJSON(r io.Reader) (map[rune]string, error) {
+	object := make(map[string]string)
+	if err := json.NewDecoder(r).Decode(&object); err != nil {
+		return nil, fmt.Errorf("reading marks: %w", err)
+	}
+
+	result := make(map[rune]string, len(object))
+	for rawKey, path := range object {
+		key, err := ParseKey(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("reading marks: %w", err)
+		}
+
+		result[key] = path
+	}
+
+	return result, nil
+}
@@ -0,0 +1,212 @@
+package marks
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bnuredini/pathsurfer/internal/datafile"
+)
+
+// The first line of every mark file written by this package. Files without it
+// are read using the legacy format, where each line holds the mark, a space
+// and the path.
+const header = "# pathsurfer marks v1"
+
+var (
+	ErrNotFound = errors.New("mark not found")
+	ErrExists   = errors.New("mark already exists")
+)
+
+// Store gives access to the marks kept in a file. Every operation reads the
+// file again so that changes made by other running instances aren't lost.
+//
+// In the file, each mark is stored on its own line as the mark, a tab and the
+// path. Backslashes, tabs, newlines and carriage returns are escaped the same
+// way as in Go strings so that any path can be stored.
+type Store struct {
+	path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path returns the path of the mark file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Load returns every mark. A missing file means that no marks have been set.
+func (s *Store) Load() (map[rune]string, error) {
+	var result map[rune]string
+
+	err := s.withLock(false, func() error {
+		var err error
+		result, err = s.read()
+		return err
+	})
+
+	return result, err
+}
+
+// Set points key to path. The previous path, if there was one, is returned.
+func (s *Store) Set(key rune, path string) (previous string, err error) {
+	err = s.Update(func(marks map[rune]string) error {
+		previous = marks[key]
+		marks[key] = path
+		return nil
+	})
+
+	return previous, err
+}
+
+// Delete removes the mark for key. ErrNotFound is returned if there's no such
+// mark.
+func (s *Store) Delete(key rune) error {
+	return s.Update(func(marks map[rune]string) error {
+		if _, ok := marks[key]; !ok {
+			return fmt.Errorf("%w: %c", ErrNotFound, key)
+		}
+
+		delete(marks, key)
+		return nil
+	})
+}
+
+// Rename moves the mark for from to to. ErrNotFound is returned if there's no
+// mark for from. ErrExists is returned if there's already a mark for to.
+func (s *Store) Rename(from, to rune) error {
+	return s.Update(func(marks map[rune]string) error {
+		path, ok := marks[from]
+		if !ok {
+			return fmt.Errorf("%w: %c", ErrNotFound, from)
+		}
+		if from == to {
+			return nil
+		}
+		if _, ok := marks[to]; ok {
+			return fmt.Errorf("%w: %c", ErrExists, to)
+		}
+
+		delete(marks, from)
+		marks[to] = path
+		return nil
+	})
+}
+
+// Update reads every mark, calls fn with them and writes them back if fn
+// doesn't return an error. Other instances can't change the file in between.
+func (s *Store) Update(fn func(marks map[rune]string) error) error {
+	return s.withLock(true, func() error {
+		marks, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		if err := fn(marks); err != nil {
+			return err
+		}
+
+		return s.write(marks)
+	})
+}
+
+// withLock runs fn while holding a lock on the mark file.
+func (s *Store) withLock(exclusive bool, fn func() error) error {
+	return datafile.WithLock(s.path, exclusive, fn)
+}
+
+func (s *Store) read() (map[rune]string, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[rune]string), nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Decode(f)
+}
+
+// write replaces the mark file with marks.
+func (s *Store) write(marks map[rune]string) error {
+	return datafile.Replace(s.path, func(w io.Writer) error {
+		return Encode(w, marks)
+	})
+}
+
+// Encode writes marks in the current file format, sorted by key.
+func Encode(w io.Writer, marks map[rune]string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, header)
+
+	keys := make([]rune, 0, len(marks))
+	for key := range marks {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(bw, "%s\t%s\n", datafile.Escape(string(key)), datafile.Escape(marks[key]))
+	}
+
+	return bw.Flush()
+}
+
+// Decode reads marks in either the current or the legacy file format.
+func Decode(r io.Reader) (map[rune]string, error) {
+	result := make(map[rune]string)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	legacy := true
+	lineIdx := 0
+
+	for scanner.Scan() {
+		lineIdx++
+		line := scanner.Text()
+
+		if lineIdx == 1 && line == header {
+			legacy = false
+			continue
+		}
+		if strings.TrimSpace(line) == "" || (!legacy && strings.HasPrefix(line, "#")) {
+			continue
+		}
+
+		var rawKey, rawPath string
+		var ok bool
+		if legacy {
+			rawKey, rawPath, ok = strings.Cut(line, " ")
+		} else {
+			rawKey, rawPath, ok = strings.Cut(line, "\t")
+		}
+		if !ok {
+			return result, fmt.Errorf("reading marks: line %v contains less than two components", lineIdx)
+		}
+
+		if !legacy {
+			var err error
+			if rawKey, err = datafile.Unescape(rawKey); err != nil {
+				return result, fmt.Errorf("reading marks: line %v: %w", lineIdx, err)
+			}
+			if rawPath, err = datafile.Unescape(rawPath); err != nil {
+				return result, fmt.Errorf("reading marks: line %v: %w", lineIdx, err)
+			}
+		}
+
+		key, err := ParseKey(rawKey)
+		if err != nil {
+			return result, fmt.Errorf("reading marks: line %v: %w", lineIdx, err)
+		}
+
+		result[key] = rawPath
+	}
+
+	return result, scanner.Err()
+}
@@ -0,0 +1,176 @@
+package marks
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	marks := map[rune]string{
+		'a': "/home/user/my projects",
+		'b': "/tmp/tab\there",
+		'c': "/tmp/new\nline",
+		'd': `C:\Users\user`,
+		'ä': "/ünïcode",
+		' ': "/space/key",
+	}
+
+	var b bytes.Buffer
+	if err := Encode(&b, marks); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Count(b.String(), "\n"); got != len(marks)+1 {
+		t.Errorf("want one line per mark plus the header, got %v lines:\n%s", got, b.String())
+	}
+
+	got, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, marks) {
+		t.Errorf("want=%q, got=%q", marks, got)
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	got, err := Decode(strings.NewReader("a /tmp\np /home/user/my projects\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[rune]string{'a': "/tmp", 'p': "/home/user/my projects"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%q, got=%q", want, got)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data := []string{
+		header + "\nab\t/tmp\n",
+		header + "\na /tmp\n",
+		header + "\na\t/tmp\\x\n",
+		"/tmp\n",
+	}
+
+	for _, contents := range data {
+		if _, err := Decode(strings.NewReader(contents)); err == nil {
+			t.Errorf("contents=%q: want an error", contents)
+		}
+	}
+}
+
+func TestEncodeDecodeJSON(t *testing.T) {
+	marks := map[rune]string{
+		'a': "/home/user/my projects",
+		'b': "/tmp/tab\there",
+		'ä': "/ünïcode",
+	}
+
+	var b bytes.Buffer
+	if err := EncodeJSON(&b, marks); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodeJSON(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, marks) {
+		t.Errorf("want=%q, got=%q", marks, got)
+	}
+
+	for _, contents := range []string{`{"ab": "/tmp"}`, `{"": "/tmp"}`, `["/tmp"]`} {
+		if _, err := DecodeJSON(strings.NewReader(contents)); err == nil {
+			t.Errorf("contents=%q: want an error", contents)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "data", "pathsurfer.mark"))
+
+	got, err := store.Load()
+	if err != nil || len(got) != 0 {
+		t.Fatalf("want no marks for a missing file, got=%v, err=%v", got, err)
+	}
+
+	if _, err := store.Set('a', "/one"); err != nil {
+		t.Fatal(err)
+	}
+	previous, err := store.Set('a', "/two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "/one" {
+		t.Errorf("want the previous path to be /one, got=%q", previous)
+	}
+
+	if _, err := store.Set('b', "/three"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Rename('b', 'a'); !errors.Is(err, ErrExists) {
+		t.Errorf("want ErrExists, got=%v", err)
+	}
+	if err := store.Rename('b', 'c'); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete('z'); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound, got=%v", err)
+	}
+	if err := store.Delete('a'); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[rune]string{'c': "/three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%q, got=%q", want, got)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(store.Path()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp") {
+			t.Errorf("temporary file %q was left behind", entry.Name())
+		}
+	}
+}
+
+func TestStoreConcurrentUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pathsurfer.mark")
+	keys := []rune("abcdefghijklmnopqrstuvwxyz")
+
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Every goroutine uses its own store, just like separate
+			// instances of the program would.
+			if _, err := NewStore(path).Set(key, "/"+string(key)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := NewStore(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(keys) {
+		t.Errorf("want %v marks, got %v: %q", len(keys), len(got), got)
+	}
+}
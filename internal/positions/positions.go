@@ -0,0 +1,112 @@
+// Package positions keeps where the cursor was in each directory between
+// sessions.
+package positions
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bnuredini/pathsurfer/internal/datafile"
+)
+
+// Max is how many directories are remembered. The ones whose positions were
+// saved the longest time ago are forgotten first.
+const Max = 1000
+
+// position is the name of the entry the cursor was on in the directory at
+// path.
+type position struct {
+	path string
+	name string
+}
+
+// Load reads the positions kept in filePath, keyed by directory. Each line in
+// the file holds the name of the entry the cursor was on, a tab and the path
+// of the directory, both escaped with datafile.Escape. Lines that can't be
+// read are skipped. A missing file means that nothing has been remembered yet.
+func Load(filePath string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	stored, err := load(filePath)
+	for _, p := range stored {
+		result[p.path] = p.name
+	}
+
+	return result, err
+}
+
+// Save records the given positions in filePath, keeping the ones of other
+// directories. They're written after the rest so that they're forgotten last.
+// Other instances can't change the file in between.
+func Save(filePath string, changed map[string]string) error {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	return datafile.WithLock(filePath, true, func() error {
+		stored, err := load(filePath)
+		if err != nil {
+			return err
+		}
+
+		stored = slices.DeleteFunc(stored, func(p position) bool {
+			_, ok := changed[p.path]
+			return ok
+		})
+		for _, path := range slices.Sorted(maps.Keys(changed)) {
+			stored = append(stored, position{path: path, name: changed[path]})
+		}
+
+		return write(filePath, stored[max(len(stored)-Max, 0):])
+	})
+}
+
+// load reads the positions kept in filePath in the order they're stored.
+func load(filePath string) ([]position, error) {
+	result := []position{}
+
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields, err := datafile.Fields(line, 2)
+		if err != nil || fields[1] == "" {
+			continue
+		}
+
+		result = append(result, position{path: fields[1], name: fields[0]})
+	}
+
+	return result, scanner.Err()
+}
+
+// write replaces filePath with the stored positions.
+func write(filePath string, stored []position) error {
+	return datafile.Replace(filePath, func(w io.Writer) error {
+		for _, p := range stored {
+			if _, err := fmt.Fprintf(w, "%s\t%s\n", datafile.Escape(p.name), datafile.Escape(p.path)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,118 @@
+package positions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.positions")
+
+	got, err := Load(filePath)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("want nothing for a missing file, got %+v, %v", got, err)
+	}
+
+	err = Save(filePath, map[string]string{
+		"/a":                "b",
+		"/path with spaces": "c d.txt",
+		"/with\ttab":        "with\nnewline",
+		`C:\backslashes`:    `\n`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Positions saved by another session are kept.
+	if err := Save(filePath, map[string]string{"/a": "e", "/f": ""}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"/a":                "e",
+		"/f":                "",
+		"/path with spaces": "c d.txt",
+		"/with\ttab":        "with\nnewline",
+		`C:\backslashes`:    `\n`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%+v, got=%+v", want, got)
+	}
+}
+
+func TestSaveConcurrently(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.positions")
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Save(filePath, map[string]string{fmt.Sprintf("/%d", i): "x"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 20 {
+		t.Errorf("want the positions of every instance to be kept, got %v", len(got))
+	}
+}
+
+func TestSaveForgetsTheOldest(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.positions")
+
+	for i := range Max {
+		if err := Save(filePath, map[string]string{fmt.Sprintf("/%d", i): "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Saving /0 again makes /1 the oldest.
+	if err := Save(filePath, map[string]string{"/0": "y", "/new": "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["/1"]; ok || len(got) != Max || got["/0"] != "y" {
+		t.Errorf("want /1 to be forgotten, got %v positions with /0=%q", len(got), got["/0"])
+	}
+}
+
+func TestLoadSkipsInvalidLines(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.positions")
+	data := "no tab\nb\t/a\nx\t\nbad\\x\t/b\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"/a": "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%+v, got=%+v", want, got)
+	}
+
+	// Saving works despite the lines that were skipped.
+	if err := Save(filePath, map[string]string{"/c": "d"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,221 @@
+// Package preview reads the beginning of a file so that it can be shown next to
+// the listing. Files are never read whole, no matter how large they are.
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// MaxBytes is the most that's read from a single file.
+	MaxBytes = 64 * 1024
+
+	// TabWidth is the distance between tab stops.
+	TabWidth = 8
+
+	// sniffLen is how much of a file is looked at to decide whether it's
+	// binary. It's the same amount git looks at.
+	sniffLen = 8000
+
+	hexBytesPerLine = 8
+)
+
+type Kind int
+
+const (
+	KindText Kind = iota
+	KindBinary
+
+	// KindSpecial is anything that isn't a regular file, such as a named pipe
+	// or a device. Those aren't opened at all since reading them could block
+	// or have side effects.
+	KindSpecial
+)
+
+// Preview holds the first lines of a file. Text files are split into lines
+// with tabs expanded and control characters replaced. Binary files are shown
+// as a hex dump.
+type Preview struct {
+	Kind  Kind
+	Lines []string
+	Size  int64
+
+	// Truncated reports whether there's more to the file than Lines.
+	Truncated bool
+}
+
+// Load reads up to maxLines lines from the file at path, but never more than
+// MaxBytes.
+func Load(path string, maxLines int) (Preview, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	result := Preview{Size: info.Size()}
+	if !info.Mode().IsRegular() {
+		result.Kind = KindSpecial
+		return result, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(io.LimitReader(f, MaxBytes))
+
+	// Peek returns what it could read along with an error if the file is
+	// shorter than sniffLen, which isn't a problem.
+	sample, _ := r.Peek(sniffLen)
+	if isBinary(sample) {
+		result.Kind = KindBinary
+		return readHex(r, result, maxLines)
+	}
+
+	return readText(r, result, maxLines)
+}
+
+func readText(r *bufio.Reader, result Preview, maxLines int) (Preview, error) {
+	read := int64(0)
+	result.Lines = []string{}
+
+	for len(result.Lines) < maxLines {
+		line, err := r.ReadString('\n')
+		read += int64(len(line))
+
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			result.Lines = append(result.Lines, sanitize(line))
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return result, err
+		}
+	}
+
+	result.Truncated = read < result.Size
+
+	return result, nil
+}
+
+func readHex(r *bufio.Reader, result Preview, maxLines int) (Preview, error) {
+	buf := make([]byte, hexBytesPerLine)
+	offset := int64(0)
+	result.Lines = []string{}
+
+	for len(result.Lines) < maxLines {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			result.Lines = append(result.Lines, hexLine(offset, buf[:n]))
+			offset += int64(n)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return result, err
+		}
+	}
+
+	result.Truncated = offset < result.Size
+
+	return result, nil
+}
+
+// hexLine formats a line of a hex dump like "0010  7f 45 4c 46  .ELF".
+func hexLine(offset int64, data []byte) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%04x ", offset)
+
+	for i := range hexBytesPerLine {
+		if i < len(data) {
+			fmt.Fprintf(&sb, " %02x", data[i])
+		} else {
+			sb.WriteString("   ")
+		}
+	}
+
+	sb.WriteString("  ")
+	for _, b := range data {
+		if b >= 0x20 && b < 0x7f {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteByte('.')
+		}
+	}
+
+	return sb.String()
+}
+
+// isBinary reports whether data looks like the beginning of a binary file,
+// meaning that it contains a NUL byte or isn't valid UTF-8.
+func isBinary(data []byte) bool {
+	if bytes.IndexByte(data, 0) != -1 {
+		return true
+	}
+
+	// The sample might end in the middle of a character.
+	for i := 0; i < utf8.UTFMax-1 && len(data) > 0; i++ {
+		if r, _ := utf8.DecodeLastRune(data); r != utf8.RuneError {
+			break
+		}
+		data = data[:len(data)-1]
+	}
+
+	return !utf8.Valid(data)
+}
+
+// sanitize expands tabs and replaces characters that would mess up the screen,
+// such as escape sequences, with '?'. Invalid UTF-8 becomes U+FFFD.
+func sanitize(line string) string {
+	var sb strings.Builder
+	col := 0
+
+	for _, r := range line {
+		switch {
+		case r == '\t':
+			spaces := TabWidth - col%TabWidth
+			sb.WriteString(strings.Repeat(" ", spaces))
+			col += spaces
+
+		case unicode.IsControl(r):
+			sb.WriteRune('?')
+			col++
+
+		default:
+			sb.WriteRune(r)
+			col += runewidth.RuneWidth(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// FormatSize formats a number of bytes using binary units, e.g. "1.5 KiB".
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
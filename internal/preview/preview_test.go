@@ -0,0 +1,122 @@
+package preview
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadText(t *testing.T) {
+	data := []struct {
+		Data          string
+		MaxLines      int
+		WantLines     []string
+		WantTruncated bool
+	}{
+		{"", 10, []string{}, false},
+		{"one\ntwo\n", 10, []string{"one", "two"}, false},
+		{"one\r\ntwo", 10, []string{"one", "two"}, false},
+		{"one\ntwo\nthree\n", 2, []string{"one", "two"}, true},
+		{"a\tb\n日本\tc\n\td\n", 10, []string{"a       b", "日本    c", "        d"}, false},
+		{"\x1b[31mred\n", 10, []string{"?[31mred"}, false},
+	}
+
+	for _, tt := range data {
+		got, err := Load(writeFile(t, tt.Data), tt.MaxLines)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.Kind != KindText {
+			t.Errorf("data=%q: want a text preview, got %v", tt.Data, got.Kind)
+		}
+		if !reflect.DeepEqual(got.Lines, tt.WantLines) {
+			t.Errorf("data=%q: want=%q, got=%q", tt.Data, tt.WantLines, got.Lines)
+		}
+		if got.Truncated != tt.WantTruncated {
+			t.Errorf("data=%q: want truncated=%v, got %v", tt.Data, tt.WantTruncated, got.Truncated)
+		}
+	}
+}
+
+func TestLoadLargeFile(t *testing.T) {
+	// A single line that's longer than MaxBytes is cut off there.
+	got, err := Load(writeFile(t, strings.Repeat("x", 2*MaxBytes)), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Lines) != 1 || len(got.Lines[0]) != MaxBytes || !got.Truncated {
+		t.Errorf("want a single truncated line of %d bytes, got %d lines", MaxBytes, len(got.Lines))
+	}
+	if got.Size != 2*MaxBytes {
+		t.Errorf("want size=%d, got %d", 2*MaxBytes, got.Size)
+	}
+}
+
+func TestLoadBinary(t *testing.T) {
+	got, err := Load(writeFile(t, "\x7fELF\x02\x01\x01\x00\x00\x00abc"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"0000  7f 45 4c 46 02 01 01 00  .ELF....",
+		"0008  00 00 61 62 63           ..abc",
+	}
+	if got.Kind != KindBinary || !reflect.DeepEqual(got.Lines, want) || got.Truncated {
+		t.Errorf("want=%q, got=%+v", want, got)
+	}
+
+	// Invalid UTF-8 counts as binary, but a character cut off at the end of
+	// the sample doesn't.
+	if !isBinary([]byte("abc\xffdef")) {
+		t.Errorf("want invalid UTF-8 to be binary")
+	}
+	if isBinary([]byte("abc\xe6\x97")) {
+		t.Errorf("want a cut-off character not to be binary")
+	}
+}
+
+func TestLoadSpecial(t *testing.T) {
+	got, err := Load(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != KindSpecial || got.Lines != nil {
+		t.Errorf("want a special preview without lines, got %+v", got)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing"), 10); !os.IsNotExist(err) {
+		t.Errorf("want a not-exist error, got %v", err)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	data := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		2 * 1024 * 1024: "2.0 MiB",
+		3 << 30:         "3.0 GiB",
+	}
+
+	for n, want := range data {
+		if got := FormatSize(n); got != want {
+			t.Errorf("n=%d: want=%q, got=%q", n, want, got)
+		}
+	}
+}
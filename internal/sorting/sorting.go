@@ -0,0 +1,244 @@
+// Package sorting orders directory listings and remembers the order picked for
+// each directory.
+package sorting
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Method int
+
+const (
+	ByName Method = iota
+	ByNatural
+	ByTime
+	BySize
+	ByExtension
+)
+
+var methodNames = []string{"name", "natural", "time", "size", "extension"}
+
+func (m Method) String() string {
+	if m < 0 || int(m) >= len(methodNames) {
+		return fmt.Sprintf("Method(%d)", int(m))
+	}
+
+	return methodNames[m]
+}
+
+// Order describes how a listing is sorted.
+//
+//   - ByName compares names byte by byte.
+//   - ByNatural compares runs of digits by their numeric value so that
+//     "file2" comes before "file10".
+//   - ByTime puts the most recently modified entries first.
+//   - BySize puts the largest entries first.
+//   - ByExtension groups entries by their extension.
+//
+// Entries that compare equal are sorted by name. Reverse flips the order, but
+// directories stay at the top if DirsFirst is set.
+type Order struct {
+	Method     Method
+	Reverse    bool
+	DirsFirst  bool
+	IgnoreCase bool
+}
+
+// String returns the order in the form read by ParseOrder.
+func (o Order) String() string {
+	parts := []string{o.Method.String()}
+
+	if o.Reverse {
+		parts = append(parts, "reverse")
+	}
+	if o.DirsFirst {
+		parts = append(parts, "dirs-first")
+	}
+	if o.IgnoreCase {
+		parts = append(parts, "ignore-case")
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// ParseOrder parses a comma-separated list made up of a method name ("name",
+// "natural", "time", "size" or "extension") and any of the flags "reverse",
+// "dirs-first" and "ignore-case", for example "natural,dirs-first". The method
+// defaults to "name".
+func ParseOrder(s string) (Order, error) {
+	result := Order{}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+
+		switch part {
+		case "":
+			continue
+		case "reverse":
+			result.Reverse = true
+		case "dirs-first":
+			result.DirsFirst = true
+		case "ignore-case":
+			result.IgnoreCase = true
+		default:
+			idx := slices.Index(methodNames, part)
+			if idx == -1 {
+				return Order{}, fmt.Errorf("unknown sort order %q", part)
+			}
+
+			result.Method = Method(idx)
+		}
+	}
+
+	return result, nil
+}
+
+// Next returns the order with the next method, wrapping around after the last
+// one. The flags stay the same.
+func (o Order) Next() Order {
+	o.Method = (o.Method + 1) % Method(len(methodNames))
+	return o
+}
+
+// Sort returns a sorted copy of entries. Sorting by time or size uses
+// the Info method of each entry, which should be cheap to call, e.g. because
+// the entries were created with fs.FileInfoToDirEntry.
+func (o Order) Sort(entries []fs.DirEntry) []fs.DirEntry {
+	result := slices.Clone(entries)
+	slices.SortStableFunc(result, o.Compare)
+
+	return result
+}
+
+// Compare returns a negative number if a comes before b, a positive number if
+// it comes after b and zero if their names are the same.
+func (o Order) Compare(a, b fs.DirEntry) int {
+	if o.DirsFirst && a.IsDir() != b.IsDir() {
+		if a.IsDir() {
+			return -1
+		}
+		return 1
+	}
+
+	result := o.compareByMethod(a, b)
+	if result == 0 {
+		result = o.compareNames(a.Name(), b.Name())
+	}
+	if result == 0 {
+		result = strings.Compare(a.Name(), b.Name())
+	}
+
+	if o.Reverse {
+		return -result
+	}
+
+	return result
+}
+
+func (o Order) compareByMethod(a, b fs.DirEntry) int {
+	switch o.Method {
+	case ByNatural:
+		return o.compareNatural(a.Name(), b.Name())
+
+	case ByTime:
+		// Newest first.
+		return modTime(b).Compare(modTime(a))
+
+	case BySize:
+		// Largest first.
+		return cmp.Compare(size(b), size(a))
+
+	case ByExtension:
+		return o.compareNames(filepath.Ext(a.Name()), filepath.Ext(b.Name()))
+	}
+
+	return 0
+}
+
+func (o Order) compareNames(a, b string) int {
+	if o.IgnoreCase {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}
+
+	return strings.Compare(a, b)
+}
+
+// compareNatural compares a and b chunk by chunk, where each chunk is either a
+// run of digits or a run of anything else. Runs of digits are compared by their
+// numeric value.
+func (o Order) compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		chunkA, restA := nextChunk(a)
+		chunkB, restB := nextChunk(b)
+
+		var result int
+		if isDigit(chunkA[0]) && isDigit(chunkB[0]) {
+			result = compareNumbers(chunkA, chunkB)
+		} else {
+			result = o.compareNames(chunkA, chunkB)
+		}
+
+		if result != 0 {
+			return result
+		}
+
+		a, b = restA, restB
+	}
+
+	return cmp.Compare(len(a), len(b))
+}
+
+func nextChunk(s string) (chunk, rest string) {
+	digits := isDigit(s[0])
+
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+
+	return s[:i], s[i:]
+}
+
+// compareNumbers compares two runs of digits of any length by their value.
+// If the values are the same, the one with fewer leading zeros comes first.
+func compareNumbers(a, b string) int {
+	trimmedA := strings.TrimLeft(a, "0")
+	trimmedB := strings.TrimLeft(b, "0")
+
+	if result := cmp.Compare(len(trimmedA), len(trimmedB)); result != 0 {
+		return result
+	}
+	if result := strings.Compare(trimmedA, trimmedB); result != 0 {
+		return result
+	}
+
+	return cmp.Compare(len(a), len(b))
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func modTime(entry fs.DirEntry) time.Time {
+	info, err := entry.Info()
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+func size(entry fs.DirEntry) int64 {
+	info, err := entry.Info()
+	if err != nil {
+		return 0
+	}
+
+	return info.Size()
+}
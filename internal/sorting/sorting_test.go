@@ -0,0 +1,159 @@
+package sorting
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testEntries(t *testing.T) []fs.DirEntry {
+	t.Helper()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"file10.txt":  {Data: make([]byte, 10), ModTime: base.Add(1 * time.Hour)},
+		"file2.txt":   {Data: make([]byte, 300), ModTime: base.Add(3 * time.Hour)},
+		"File3.go":    {Data: make([]byte, 20), ModTime: base.Add(2 * time.Hour)},
+		"archive.zip": {Data: make([]byte, 5), ModTime: base},
+		"docs/a.md":   {},
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entries
+}
+
+func names(entries []fs.DirEntry) []string {
+	result := []string{}
+	for _, entry := range entries {
+		result = append(result, entry.Name())
+	}
+
+	return result
+}
+
+func TestSort(t *testing.T) {
+	data := []struct {
+		Order string
+		Want  []string
+	}{
+		{"name", []string{"File3.go", "archive.zip", "docs", "file10.txt", "file2.txt"}},
+		{"name,ignore-case", []string{"archive.zip", "docs", "file10.txt", "file2.txt", "File3.go"}},
+		{"natural,ignore-case", []string{"archive.zip", "docs", "file2.txt", "File3.go", "file10.txt"}},
+		{"natural,ignore-case,dirs-first", []string{"docs", "archive.zip", "file2.txt", "File3.go", "file10.txt"}},
+		{"natural,ignore-case,dirs-first,reverse", []string{"docs", "file10.txt", "File3.go", "file2.txt", "archive.zip"}},
+		{"size,dirs-first", []string{"docs", "file2.txt", "File3.go", "file10.txt", "archive.zip"}},
+		{"time", []string{"file2.txt", "File3.go", "file10.txt", "archive.zip", "docs"}},
+		{"extension", []string{"docs", "File3.go", "file10.txt", "file2.txt", "archive.zip"}},
+	}
+
+	entries := testEntries(t)
+
+	for _, tt := range data {
+		order, err := ParseOrder(tt.Order)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := names(order.Sort(entries)); !reflect.DeepEqual(got, tt.Want) {
+			t.Errorf("order=%q: want=%q, got=%q", tt.Order, tt.Want, got)
+		}
+	}
+}
+
+func TestCompareNatural(t *testing.T) {
+	data := []struct {
+		A, B string
+		Want int
+	}{
+		{"a2", "a10", -1},
+		{"a10", "a2", 1},
+		{"a02", "a2", 1},
+		{"a2b", "a2a", 1},
+		{"v1.10.0", "v1.9.3", 1},
+		{"99999999999999999999", "100000000000000000000", -1},
+		{"abc", "abc", 0},
+		{"ab", "abc", -1},
+	}
+
+	for _, tt := range data {
+		if got := (Order{}).compareNatural(tt.A, tt.B); got != tt.Want {
+			t.Errorf("a=%q, b=%q: want=%v, got=%v", tt.A, tt.B, tt.Want, got)
+		}
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	for _, s := range []string{"name", "natural,reverse,dirs-first,ignore-case", "size,dirs-first"} {
+		order, err := ParseOrder(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := order.String(); got != s {
+			t.Errorf("want=%q, got=%q", s, got)
+		}
+	}
+
+	if _, err := ParseOrder("name,upside-down"); err == nil {
+		t.Errorf("want an error for an unknown order")
+	}
+
+	order := Order{Method: ByExtension, Reverse: true}
+	if got, want := order.Next(), (Order{Method: ByName, Reverse: true}); got != want {
+		t.Errorf("want=%v, got=%v", want, got)
+	}
+}
+
+func TestStore(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "data", "pathsurfer.sort")
+	defaultOrder := Order{}
+	natural := Order{Method: ByNatural, DirsFirst: true}
+
+	if err := SaveOrder(filePath, "/a", natural, defaultOrder); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveOrder(filePath, "/b with\ttab", Order{Method: BySize}, defaultOrder); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveOrder(filePath, "/b with\ttab", defaultOrder, defaultOrder); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveOrder(filePath, "/c with\nnewline\\", Order{Method: ByTime}, defaultOrder); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadOrders(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Order{"/a": natural, "/c with\nnewline\\": {Method: ByTime}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%v, got=%v", want, got)
+	}
+}
+
+func TestLoadOrdersSkipsInvalidLines(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "pathsurfer.sort")
+	data := "no tab\nnatural\t/a\nbogus\t/b\nsize\t/bad\\escape\\x\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadOrders(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Order{"/a": {Method: ByNatural}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%v, got=%v", want, got)
+	}
+}
@@ -0,0 +1,81 @@
+package sorting
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bnuredini/pathsurfer/internal/datafile"
+)
+
+// LoadOrders reads the orders picked for each directory from filePath. Each
+// line in the file holds an order in the form read by ParseOrder, a tab and the
+// path of the directory, escaped with datafile.Escape. Lines that can't be
+// read are skipped. A missing file means that no orders have been picked.
+func LoadOrders(filePath string) (map[string]Order, error) {
+	result := make(map[string]Order)
+
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields, err := datafile.Fields(line, 2)
+		if err != nil {
+			continue
+		}
+
+		order, err := ParseOrder(fields[0])
+		if err != nil {
+			continue
+		}
+
+		result[fields[1]] = order
+	}
+
+	return result, scanner.Err()
+}
+
+// SaveOrder records the order picked for dir in filePath, keeping the orders of
+// other directories. If the order is the same as defaultOrder, dir is removed
+// from the file instead. Other instances can't change the file in between.
+func SaveOrder(filePath, dir string, order, defaultOrder Order) error {
+	return datafile.WithLock(filePath, true, func() error {
+		orders, err := LoadOrders(filePath)
+		if err != nil {
+			return err
+		}
+
+		if order == defaultOrder {
+			delete(orders, dir)
+		} else {
+			orders[dir] = order
+		}
+
+		return datafile.Replace(filePath, func(w io.Writer) error {
+			for _, path := range slices.Sorted(maps.Keys(orders)) {
+				if _, err := fmt.Fprintf(w, "%s\t%s\n", orders[path], datafile.Escape(path)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
@@ -0,0 +1,636 @@
+package tui
+
+import (
+	"errors"
+	"maps"
+	"path/filepath"
+	"slices"
+)
+
+// Action is something the user can do by pressing keys. Actions are mapped to
+// keys through a Keymap.
+type Action string
+
+const (
+	ActionMoveDown          Action = "move-down"
+	ActionMoveUp            Action = "move-up"
+	ActionParentDir         Action = "parent-dir"
+	ActionEnterDir          Action = "enter-dir"
+	ActionToggleHidden      Action = "toggle-hidden"
+	ActionToggleIgnore      Action = "toggle-ignore"
+	ActionToggleInfo        Action = "toggle-info"
+	ActionCycleSort         Action = "cycle-sort"
+	ActionReverseSort       Action = "reverse-sort"
+	ActionToggleDirsFirst   Action = "toggle-dirs-first"
+	ActionToggleSortCase    Action = "toggle-sort-case"
+	ActionStartSearch       Action = "start-search"
+	ActionSetMark           Action = "set-mark"
+	ActionJumpToMark        Action = "jump-to-mark"
+	ActionGoToTop           Action = "go-to-top"
+	ActionGoToBottom        Action = "go-to-bottom"
+	ActionPageDown          Action = "page-down"
+	ActionPageUp            Action = "page-up"
+	ActionQuit              Action = "quit"
+	ActionToggleSelect      Action = "toggle-select"
+	ActionVisualSelect      Action = "visual-select"
+	ActionClearSelection    Action = "clear-selection"
+	ActionQuitWithSelection Action = "quit-with-selection"
+	ActionSearchAccept      Action = "search-accept"
+	ActionSearchCancel      Action = "search-cancel"
+	ActionSearchEnterDir    Action = "search-enter-dir"
+	ActionSearchParentDir   Action = "search-parent-dir"
+	ActionSearchDeleteChar  Action = "search-delete-char"
+	ActionManageMarks       Action = "manage-marks"
+	ActionMarksDown         Action = "marks-down"
+	ActionMarksUp           Action = "marks-up"
+	ActionMarksJump         Action = "marks-jump"
+	ActionMarksDelete       Action = "marks-delete"
+	ActionMarksRename       Action = "marks-rename"
+	ActionMarksRepoint      Action = "marks-repoint"
+	ActionMarksClose        Action = "marks-close"
+	ActionStartFinder       Action = "start-finder"
+	ActionFinderAccept      Action = "finder-accept"
+	ActionFinderCancel      Action = "finder-cancel"
+	ActionFinderDown        Action = "finder-down"
+	ActionFinderUp          Action = "finder-up"
+	ActionFinderDeleteChar  Action = "finder-delete-char"
+	ActionHistoryBack       Action = "history-back"
+	ActionHistoryForward    Action = "history-forward"
+	ActionShowHistory       Action = "show-history"
+	ActionHistoryDown       Action = "history-down"
+	ActionHistoryUp         Action = "history-up"
+	ActionHistoryJump       Action = "history-jump"
+	ActionHistoryClose      Action = "history-close"
+)
+
+type ActionSpec struct {
+	// Mode is the mode in which the action can be bound.
+	Mode        Mode
+	Description string
+
+	run func(State) (State, []Effect)
+}
+
+// Actions is the registry of every action that can be bound to keys.
+var Actions = map[Action]ActionSpec{
+	ActionMoveDown:          {ModeDefault, "Move down in the file list", moveDown},
+	ActionMoveUp:            {ModeDefault, "Move up in the file list", moveUp},
+	ActionParentDir:         {ModeDefault, "Go back one directory", goToParentDir},
+	ActionEnterDir:          {ModeDefault, "Change into the selected directory", enterDir},
+	ActionToggleHidden:      {ModeDefault, "Toggle hidden files in the list", toggleHidden},
+	ActionToggleIgnore:      {ModeDefault, "Toggle files matched by ignore files in the list", toggleIgnore},
+	ActionToggleInfo:        {ModeDefault, "Toggle details about the selected entry", toggleInfo},
+	ActionCycleSort:         {ModeDefault, "Sort the current directory differently", cycleSort},
+	ActionReverseSort:       {ModeDefault, "Reverse the order of the current directory", reverseSort},
+	ActionToggleDirsFirst:   {ModeDefault, "Toggle directories before files in the current directory", toggleDirsFirst},
+	ActionToggleSortCase:    {ModeDefault, "Toggle case-insensitive sorting in the current directory", toggleSortCase},
+	ActionStartSearch:       {ModeDefault, "Enter search mode", startSearch},
+	ActionStartFinder:       {ModeDefault, "Search everything below the current directory", startFinder},
+	ActionSetMark:           {ModeDefault, "Set a mark for the current directory", startSettingMark},
+	ActionJumpToMark:        {ModeDefault, "Jump to a marked directory", startJumpingToMark},
+	ActionManageMarks:       {ModeDefault, "Open the mark manager", openMarkManager},
+	ActionToggleSelect:      {ModeDefault, "Add the selected entry to the selection or remove it", toggleSelect},
+	ActionVisualSelect:      {ModeDefault, "Start a range of entries to select, or add the range to the selection", visualSelect},
+	ActionClearSelection:    {ModeDefault, "Clear the selection", clearSelection},
+	ActionQuitWithSelection: {ModeDefault, "Quit and print the selected paths", quitWithSelection},
+	ActionGoToTop:           {ModeDefault, "Go to the first entry", goToTop},
+	ActionGoToBottom:        {ModeDefault, "Go to the last entry", goToBottom},
+	ActionPageDown:          {ModeDefault, "Move down by a big jump", pageDown},
+	ActionPageUp:            {ModeDefault, "Move up by a big jump", pageUp},
+	ActionQuit:              {ModeDefault, "Quit the program", quit},
+	ActionSearchAccept:      {ModeSearch, "Keep the matches and leave search mode", acceptSearch},
+	ActionSearchCancel:      {ModeSearch, "Leave search mode", cancelSearch},
+	ActionSearchEnterDir:    {ModeSearch, "Change into the first match", searchEnterDir},
+	ActionSearchParentDir:   {ModeSearch, "Go back one directory", searchParentDir},
+	ActionSearchDeleteChar:  {ModeSearch, "Delete the last character of the search", searchDeleteChar},
+	ActionMarksDown:         {ModeMarkManager, "Move down in the mark list", marksDown},
+	ActionMarksUp:           {ModeMarkManager, "Move up in the mark list", marksUp},
+	ActionMarksJump:         {ModeMarkManager, "Jump to the selected mark", marksJump},
+	ActionMarksDelete:       {ModeMarkManager, "Delete the selected mark", marksDelete},
+	ActionMarksRename:       {ModeMarkManager, "Change the key of the selected mark", marksRename},
+	ActionMarksRepoint:      {ModeMarkManager, "Point the selected mark to the current directory", marksRepoint},
+	ActionMarksClose:        {ModeMarkManager, "Close the mark manager", closeMarkManager},
+	ActionFinderAccept:      {ModeFinder, "Go to the selected directory or to the parent of the selected file", acceptFinder},
+	ActionFinderCancel:      {ModeFinder, "Close the finder", cancelFinder},
+	ActionFinderDown:        {ModeFinder, "Move down in the matches", finderDown},
+	ActionFinderUp:          {ModeFinder, "Move up in the matches", finderUp},
+	ActionFinderDeleteChar:  {ModeFinder, "Delete the last character of the query", finderDeleteChar},
+	ActionHistoryBack:       {ModeDefault, "Go back to the previous directory in the history", historyBack},
+	ActionHistoryForward:    {ModeDefault, "Go forward to the next directory in the history", historyForward},
+	ActionShowHistory:       {ModeDefault, "Open the list of recently visited directories", showHistory},
+	ActionHistoryDown:       {ModeHistory, "Move down to an older directory in the history", historyDown},
+	ActionHistoryUp:         {ModeHistory, "Move up to a newer directory in the history", historyUp},
+	ActionHistoryJump:       {ModeHistory, "Go to the selected directory in the history", historyJump},
+	ActionHistoryClose:      {ModeHistory, "Close the history", closeHistory},
+}
+
+func moveDown(s State) (State, []Effect) {
+	if len(s.Files) == 0 {
+		return s, nil
+	}
+
+	s.SelectedIdx = (s.SelectedIdx + 1) % len(s.Files)
+	return s.withScrollOffset(), nil
+}
+
+func moveUp(s State) (State, []Effect) {
+	if len(s.Files) == 0 {
+		return s, nil
+	}
+
+	s.SelectedIdx = (s.SelectedIdx - 1 + len(s.Files)) % len(s.Files)
+	return s.withScrollOffset(), nil
+}
+
+func goToParentDir(s State) (State, []Effect) {
+	s = s.rememberPosition()
+
+	oldPath := s.Path
+	newPath := filepath.Dir(s.Path)
+
+	target := cursorTarget{name: filepath.Base(oldPath)}
+	if _, ok := s.PositionHistory[newPath]; ok {
+		target = s.rememberedPosition(newPath)
+	}
+
+	return s.changeDirectory(newPath, target)
+}
+
+func enterDir(s State) (State, []Effect) {
+	f, ok := s.SelectedEntry()
+	if !ok || !f.IsDir() {
+		return s, nil
+	}
+
+	s = s.rememberPosition()
+	newPath := filepath.Join(s.Path, f.Name())
+
+	return s.changeDirectory(newPath, s.rememberedPosition(newPath))
+}
+
+func toggleHidden(s State) (State, []Effect) {
+	s.ShowHiddenFiles = !s.ShowHiddenFiles
+
+	// Force the side panes to be loaded again so that they are filtered with
+	// the new setting.
+	s.ParentPath = ""
+	s.ChildPath = ""
+
+	return s.reload()
+}
+
+func toggleIgnore(s State) (State, []Effect) {
+	s.RespectIgnore = !s.RespectIgnore
+
+	// Same as with hidden files. The ignore files are read again as well so
+	// that toggling twice picks up changes to them.
+	s.ParentPath = ""
+	s.ChildPath = ""
+
+	s, effects := s.reload()
+	return s, append([]Effect{EffectReloadIgnoreFiles{}}, effects...)
+}
+
+func toggleInfo(s State) (State, []Effect) {
+	s.ShowInfo = !s.ShowInfo
+	return s, nil
+}
+
+func cycleSort(s State) (State, []Effect) {
+	return s.withSortOrder(s.sortOrderFor(s.Path).Next())
+}
+
+func reverseSort(s State) (State, []Effect) {
+	order := s.sortOrderFor(s.Path)
+	order.Reverse = !order.Reverse
+
+	return s.withSortOrder(order)
+}
+
+func toggleDirsFirst(s State) (State, []Effect) {
+	order := s.sortOrderFor(s.Path)
+	order.DirsFirst = !order.DirsFirst
+
+	return s.withSortOrder(order)
+}
+
+func toggleSortCase(s State) (State, []Effect) {
+	order := s.sortOrderFor(s.Path)
+	order.IgnoreCase = !order.IgnoreCase
+
+	return s.withSortOrder(order)
+}
+
+func toggleSelect(s State) (State, []Effect) {
+	f, ok := s.SelectedEntry()
+	if !ok {
+		return s, nil
+	}
+
+	if !s.Pick.accepts(f.IsDir()) {
+		s.Err = s.Pick.errNotPickable()
+		return s, nil
+	}
+
+	path := filepath.Join(s.Path, f.Name())
+	selection := maps.Clone(s.Selection)
+	if selection == nil {
+		selection = make(map[string]bool)
+	}
+
+	if selection[path] {
+		delete(selection, path)
+	} else {
+		selection[path] = true
+	}
+	s.Selection = selection
+
+	return moveDown(s)
+}
+
+func visualSelect(s State) (State, []Effect) {
+	if _, _, ok := s.visualRange(); ok {
+		s.Selection = s.markedPaths()
+		s.VisualDir = ""
+		s.VisualStart = ""
+
+		return s, nil
+	}
+
+	f, ok := s.SelectedEntry()
+	if !ok {
+		return s, nil
+	}
+
+	s.VisualDir = s.Path
+	s.VisualStart = f.Name()
+
+	return s, nil
+}
+
+func clearSelection(s State) (State, []Effect) {
+	s.Selection = nil
+	s.VisualDir = ""
+	s.VisualStart = ""
+
+	return s, nil
+}
+
+// quitWithSelection prints the selected paths, or the entry under the cursor
+// if nothing is selected. In an empty directory, the directory itself is
+// printed unless only files can be picked.
+func quitWithSelection(s State) (State, []Effect) {
+	paths := slices.Sorted(maps.Keys(s.markedPaths()))
+
+	if len(paths) == 0 {
+		f, ok := s.SelectedEntry()
+		isDir := !ok || f.IsDir()
+		if !s.Pick.accepts(isDir) {
+			s.Err = s.Pick.errNotPickable()
+			return s, nil
+		}
+
+		if ok {
+			paths = append(paths, filepath.Join(s.Path, f.Name()))
+		} else {
+			paths = append(paths, s.Path)
+		}
+	}
+
+	return s, []Effect{EffectQuitWithSelection{Paths: paths}}
+}
+
+func startSearch(s State) (State, []Effect) {
+	s.Mode = ModeSearch
+	s.SearchBarPrefix = SearchBarPrefixSearching
+
+	// The marker is at the top of the list while searching.
+	s.SelectedIdx = 0
+	s.ScrollOffset = 0
+
+	return s, nil
+}
+
+func startSettingMark(s State) (State, []Effect) {
+	s.Mode = ModeRecordingMark
+	return s, nil
+}
+
+func startJumpingToMark(s State) (State, []Effect) {
+	s.Mode = ModeListeningForMark
+	return s, nil
+}
+
+func goToTop(s State) (State, []Effect) {
+	s.SelectedIdx = 0
+	s.ScrollOffset = 0
+
+	return s, nil
+}
+
+func goToBottom(s State) (State, []Effect) {
+	s.SelectedIdx = max(len(s.Files)-1, 0)
+	s.ScrollOffset = max((len(s.Files)-1)-(s.listHeight()-1), 0)
+
+	return s, nil
+}
+
+func pageDown(s State) (State, []Effect) {
+	if s.SelectedIdx >= len(s.Files)-1 {
+		return s, nil
+	}
+
+	s.SelectedIdx = min(s.SelectedIdx+BigJumpLength, len(s.Files)-1)
+	return s.withScrollOffset(), nil
+}
+
+func pageUp(s State) (State, []Effect) {
+	s.SelectedIdx = max(s.SelectedIdx-BigJumpLength, 0)
+	return s.withScrollOffset(), nil
+}
+
+// quit prints the current directory for the shell wrappers, unless entries
+// are being picked, in which case quitting cancels.
+func quit(s State) (State, []Effect) {
+	if s.Pick != PickNone {
+		return s, []Effect{EffectCancel{}}
+	}
+
+	return s, []Effect{EffectQuit{Path: s.Path}}
+}
+
+func acceptSearch(s State) (State, []Effect) {
+	if s.SearchEntry == "" || len(s.Files) == 0 {
+		s.Files = s.Entries
+	}
+
+	s.Mode = ModeDefault
+	s.SearchEntry = ""
+
+	// The user is now done with searching. Set the marker to point to the
+	// first entry.
+	s.SelectedIdx = 0
+	s.ScrollOffset = 0
+	s.SearchBarPrefix = SearchBarPrefixSearched
+
+	return s, nil
+}
+
+func cancelSearch(s State) (State, []Effect) {
+	// Disable search mode and ignore the current search string. This is
+	// consistent with how searching work in Vim.
+	s.Mode = ModeDefault
+	s.SearchEntry = ""
+	s.SearchBarPrefix = SearchBarPrefixNavigating
+	s.Files = s.Entries
+
+	return s.withScrollOffset(), nil
+}
+
+func searchEnterDir(s State) (State, []Effect) {
+	if s.SearchEntry == "" {
+		s.SearchBarPrefix = SearchBarPrefixNavigating
+	} else {
+		s.SearchBarPrefix = SearchBarPrefixSearching
+	}
+
+	s.SearchEntry = ""
+
+	f, ok := s.SelectedEntry()
+	if !ok || !f.IsDir() {
+		s.Files = s.Entries
+		return s, nil
+	}
+
+	s = s.rememberPosition()
+	newPath := filepath.Join(s.Path, f.Name())
+
+	return s.changeDirectory(newPath, s.rememberedPosition(newPath))
+}
+
+func searchParentDir(s State) (State, []Effect) {
+	s.SearchEntry = ""
+
+	parentPath := filepath.Dir(filepath.Clean(s.Path))
+	return s.changeDirectory(parentPath, cursorTarget{idx: 0})
+}
+
+func searchDeleteChar(s State) (State, []Effect) {
+	if len(s.SearchEntry) == 0 {
+		return s, nil
+	}
+
+	entryRunes := []rune(s.SearchEntry)
+	s.SearchEntry = string(entryRunes[:len(entryRunes)-1])
+
+	if s.SearchEntry == "" {
+		s.Files = s.Entries
+	} else {
+		s.Files = s.sortOrderFor(s.Path).Sort(searchInDir(s.SearchEntry, s.Entries))
+	}
+
+	s.SelectedIdx = 0
+	s.ScrollOffset = 0
+
+	return s, nil
+}
+
+func openMarkManager(s State) (State, []Effect) {
+	s.Mode = ModeMarkManager
+	s.MarkIdx = 0
+	s.RenamingMark = false
+
+	// The marks are read again since other instances might have changed them
+	// and since the directories they point to might be gone by now.
+	return s, []Effect{EffectLoadMarks{}}
+}
+
+func marksDown(s State) (State, []Effect) {
+	if len(s.Marks) == 0 {
+		return s, nil
+	}
+
+	s.MarkIdx = (s.MarkIdx + 1) % len(s.Marks)
+	return s, nil
+}
+
+func marksUp(s State) (State, []Effect) {
+	if len(s.Marks) == 0 {
+		return s, nil
+	}
+
+	s.MarkIdx = (s.MarkIdx - 1 + len(s.Marks)) % len(s.Marks)
+	return s, nil
+}
+
+func marksJump(s State) (State, []Effect) {
+	key, ok := s.SelectedMark()
+	if !ok {
+		return s, nil
+	}
+
+	s, effects := jumpToMark(s, key)
+	if s.Err == nil {
+		s.Mode = ModeDefault
+	}
+
+	return s, effects
+}
+
+func marksDelete(s State) (State, []Effect) {
+	key, ok := s.SelectedMark()
+	if !ok {
+		return s, nil
+	}
+
+	return s, []Effect{EffectDeleteMark{Key: key}}
+}
+
+func marksRename(s State) (State, []Effect) {
+	if _, ok := s.SelectedMark(); !ok {
+		return s, nil
+	}
+
+	s.RenamingMark = true
+	return s, nil
+}
+
+func marksRepoint(s State) (State, []Effect) {
+	key, ok := s.SelectedMark()
+	if !ok {
+		return s, nil
+	}
+
+	return s, []Effect{EffectStoreMark{Key: key, Path: s.Path}}
+}
+
+func closeMarkManager(s State) (State, []Effect) {
+	s.Mode = ModeDefault
+	s.RenamingMark = false
+
+	return s, nil
+}
+
+func startFinder(s State) (State, []Effect) {
+	s.Mode = ModeFinder
+	s.Finder = FinderState{Root: s.Path, ID: s.Finder.ID + 1}
+
+	return s, []Effect{EffectStartFinder{
+		ID:            s.Finder.ID,
+		Root:          s.Path,
+		ShowHidden:    s.ShowHiddenFiles,
+		RespectIgnore: s.RespectIgnore,
+	}}
+}
+
+func acceptFinder(s State) (State, []Effect) {
+	match, ok := s.Finder.SelectedMatch()
+	root := s.Finder.Root
+
+	s.Mode = ModeDefault
+	// Only the ID is kept so that results of the cancelled walk are ignored.
+	s.Finder = FinderState{ID: s.Finder.ID}
+	effects := []Effect{EffectStopFinder{}}
+
+	if !ok {
+		return s, effects
+	}
+
+	path := filepath.Join(root, filepath.FromSlash(match.Entry.Path))
+	s = s.rememberPosition()
+
+	var dirEffects []Effect
+	if match.Entry.IsDir {
+		s, dirEffects = s.changeDirectory(path, s.rememberedPosition(path))
+	} else {
+		// Files are shown selected in their directory.
+		s, dirEffects = s.changeDirectory(filepath.Dir(path), cursorTarget{name: filepath.Base(path)})
+	}
+
+	return s, append(effects, dirEffects...)
+}
+
+func cancelFinder(s State) (State, []Effect) {
+	s.Mode = ModeDefault
+	s.Finder = FinderState{ID: s.Finder.ID}
+
+	return s, []Effect{EffectStopFinder{}}
+}
+
+func finderDown(s State) (State, []Effect) {
+	if len(s.Finder.Matches) == 0 {
+		return s, nil
+	}
+
+	s.Finder.SelectedIdx = (s.Finder.SelectedIdx + 1) % len(s.Finder.Matches)
+	return s, nil
+}
+
+func finderUp(s State) (State, []Effect) {
+	if len(s.Finder.Matches) == 0 {
+		return s, nil
+	}
+
+	s.Finder.SelectedIdx = (s.Finder.SelectedIdx - 1 + len(s.Finder.Matches)) % len(s.Finder.Matches)
+	return s, nil
+}
+
+func finderDeleteChar(s State) (State, []Effect) {
+	if s.Finder.Query == "" {
+		return s, nil
+	}
+
+	queryRunes := []rune(s.Finder.Query)
+	s.Finder.Query = string(queryRunes[:len(queryRunes)-1])
+	s.Finder.SelectedIdx = 0
+	s.Finder = s.Finder.rank()
+
+	return s, nil
+}
+
+func historyBack(s State) (State, []Effect) {
+	if s.HistoryIdx <= 0 {
+		s.Err = errors.New("going back: this is the oldest directory in the history")
+		return s, nil
+	}
+
+	return s.moveInHistory(s.HistoryIdx - 1)
+}
+
+func historyForward(s State) (State, []Effect) {
+	if s.HistoryIdx >= len(s.History)-1 {
+		s.Err = errors.New("going forward: this is the newest directory in the history")
+		return s, nil
+	}
+
+	return s.moveInHistory(s.HistoryIdx + 1)
+}
+
+func showHistory(s State) (State, []Effect) {
+	s.Mode = ModeHistory
+	s.HistoryMenuIdx = s.HistoryIdx
+
+	return s, nil
+}
+
+// The history popup lists the newest directory first, so moving down goes
+// back in time.
+func historyDown(s State) (State, []Effect) {
+	s.HistoryMenuIdx = max(s.HistoryMenuIdx-1, 0)
+	return s, nil
+}
+
+func historyUp(s State) (State, []Effect) {
+	s.HistoryMenuIdx = min(s.HistoryMenuIdx+1, max(len(s.History)-1, 0))
+	return s, nil
+}
+
+func historyJump(s State) (State, []Effect) {
+	s.Mode = ModeDefault
+	return s.moveInHistory(s.HistoryMenuIdx)
+}
+
+func closeHistory(s State) (State, []Effect) {
+	s.Mode = ModeDefault
+	return s, nil
+}
@@ -0,0 +1,194 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/bnuredini/pathsurfer/internal/fuzzy"
+	"github.com/bnuredini/pathsurfer/internal/walk"
+)
+
+// FinderState is the state of the finder, which searches everything below the
+// directory it was opened in instead of just the current listing.
+type FinderState struct {
+	Root  string
+	Query string
+
+	// Candidates holds every path found below Root so far, in the order in
+	// which they were found. Matches holds the ones that match Query, best
+	// first. Without a query, every candidate matches and they're sorted by
+	// path.
+	Candidates  []walk.Entry
+	Matches     []FinderMatch
+	SelectedIdx int
+
+	// Done is set once the whole tree has been walked.
+	Done bool
+
+	// ID tells the results of the current walk apart from those of walks that
+	// have been cancelled.
+	ID int
+}
+
+// FinderMatch is a candidate that matches the query. Indexes holds the byte
+// offsets of the matched characters in the candidate's path and Score how well
+// it matches.
+type FinderMatch struct {
+	Entry   walk.Entry
+	Indexes []int
+	Score   int
+}
+
+// SelectedMatch returns the match under the cursor, if there is one.
+func (f FinderState) SelectedMatch() (FinderMatch, bool) {
+	if f.SelectedIdx < 0 || f.SelectedIdx >= len(f.Matches) {
+		return FinderMatch{}, false
+	}
+
+	return f.Matches[f.SelectedIdx], true
+}
+
+// rank matches every candidate against the query again. The cursor stays at
+// the top if it's there already. Otherwise, it follows the selected path.
+func (f FinderState) rank() FinderState {
+	selected, hadSelection := f.SelectedMatch()
+	f.Matches = f.match(f.Candidates)
+
+	return f.keepSelection(selected, hadSelection)
+}
+
+// addCandidates adds entries that have just been found. Only they are matched
+// against the query, and the matches are merged into the ones so far.
+func (f FinderState) addCandidates(entries []walk.Entry) FinderState {
+	selected, hadSelection := f.SelectedMatch()
+
+	// Older states only see the part of Candidates they were given, so
+	// appending to it doesn't change them.
+	f.Candidates = append(f.Candidates, entries...)
+	f.Matches = mergeMatches(f.Matches, f.match(entries), f.compareMatches)
+
+	return f.keepSelection(selected, hadSelection)
+}
+
+// match returns the entries that match the query, in the order in which
+// they're shown.
+func (f FinderState) match(entries []walk.Entry) []FinderMatch {
+	result := make([]FinderMatch, 0, len(entries))
+
+	if f.Query == "" {
+		for _, entry := range entries {
+			result = append(result, FinderMatch{Entry: entry})
+		}
+		slices.SortFunc(result, f.compareMatches)
+
+		return result
+	}
+
+	paths := make([]string, 0, len(entries))
+	isDir := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+		isDir[entry.Path] = entry.IsDir
+	}
+
+	for _, match := range fuzzy.FindPaths(f.Query, paths) {
+		result = append(result, FinderMatch{
+			Entry:   walk.Entry{Path: match.CandidateString, IsDir: isDir[match.CandidateString]},
+			Indexes: match.Indexes,
+			Score:   match.Score,
+		})
+	}
+
+	return result
+}
+
+// compareMatches orders matches the best first. Without a query, they're
+// sorted by path.
+func (f FinderState) compareMatches(a, b FinderMatch) int {
+	if f.Query == "" {
+		return strings.Compare(a.Entry.Path, b.Entry.Path)
+	}
+
+	return fuzzy.ComparePathMatches(
+		fuzzy.Match{CandidateString: a.Entry.Path, Score: a.Score},
+		fuzzy.Match{CandidateString: b.Entry.Path, Score: b.Score},
+	)
+}
+
+// keepSelection puts the cursor back on selected after the matches have
+// changed, unless it was at the top.
+func (f FinderState) keepSelection(selected FinderMatch, hadSelection bool) FinderState {
+	idx := 0
+	if hadSelection && f.SelectedIdx != 0 {
+		idx = max(slices.IndexFunc(f.Matches, func(m FinderMatch) bool {
+			return m.Entry.Path == selected.Entry.Path
+		}), 0)
+	}
+	f.SelectedIdx = idx
+
+	return f
+}
+
+// mergeMatches merges two lists of matches that are both sorted by cmp into a
+// new one.
+func mergeMatches(a, b []FinderMatch, cmp func(a, b FinderMatch) int) []FinderMatch {
+	result := make([]FinderMatch, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if cmp(b[0], a[0]) < 0 {
+			result = append(result, b[0])
+			b = b[1:]
+		} else {
+			result = append(result, a[0])
+			a = a[1:]
+		}
+	}
+
+	result = append(result, a...)
+	return append(result, b...)
+}
+
+func handleFinderResults(s State, ev EventFinderResults) State {
+	if s.Mode != ModeFinder || ev.ID != s.Finder.ID {
+		// The finder has been closed or opened again since.
+		return s
+	}
+
+	if ev.Err != nil {
+		s.Err = fmt.Errorf("finding files: %w", ev.Err)
+	}
+	s.Finder.Done = ev.Done
+
+	if len(ev.Entries) > 0 {
+		s.Finder = s.Finder.addCandidates(ev.Entries)
+	}
+
+	return s
+}
+
+func handleKeyPressInFinder(s State, ev EventKey) (State, []Effect) {
+	s, actions, typed := resolveTypedKeys(s, ev)
+	if typed != "" {
+		s.Finder = s.Finder.typeInQuery(typed)
+	}
+	if len(actions) > 0 || len(s.PendingKeys) > 0 {
+		return runActions(s, actions)
+	}
+
+	if ev.Key == tcell.KeyRune {
+		s.Finder = s.Finder.typeInQuery(string(ev.Rune))
+	}
+
+	return s, nil
+}
+
+// typeInQuery adds text to the query and ranks the candidates again.
+func (f FinderState) typeInQuery(text string) FinderState {
+	f.Query += text
+	f.SelectedIdx = 0
+
+	return f.rank()
+}
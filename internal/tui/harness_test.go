@@ -0,0 +1,293 @@
+package tui
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+const (
+	harnessWidth  = 100
+	harnessHeight = 16
+)
+
+// harness runs a Program against a simulated screen in a temporary directory
+// tree.
+type harness struct {
+	t       *testing.T
+	root    string
+	config  *conf.Config
+	screen  tcell.SimulationScreen
+	program *Program
+}
+
+// newHarness creates the given tree under a temporary directory and starts a
+// program in its root. Paths ending with a slash are created as directories,
+// everything else is created as a file with the given contents.
+func newHarness(t *testing.T, tree map[string]string) *harness {
+	t.Helper()
+
+	// The root gets a directory of its own so that the parent pane always
+	// looks the same.
+	root := filepath.Join(t.TempDir(), "root")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, root, tree)
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		t.Fatalf("Failed to initialize screen: %v", err)
+	}
+	screen.SetSize(harnessWidth, harnessHeight)
+	t.Cleanup(screen.Fini)
+
+	dataDir := t.TempDir()
+	config := &conf.Config{
+		LogFilePath:      filepath.Join(dataDir, "pathsurfer.log"),
+		MarkFilePath:     filepath.Join(dataDir, "pathsurfer.mark"),
+		FrecencyFilePath: filepath.Join(dataDir, "pathsurfer.frecency"),
+		HistoryFilePath:  filepath.Join(dataDir, "pathsurfer.history"),
+		PositionFilePath: filepath.Join(dataDir, "pathsurfer.positions"),
+		SortFilePath:     filepath.Join(dataDir, "pathsurfer.sort"),
+	}
+
+	h := &harness{t: t, root: root, config: config, screen: screen}
+	h.start(root)
+
+	return h
+}
+
+func (h *harness) start(path string) {
+	h.t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	program, err := NewProgram(h.screen, h.config, logger, path)
+	if err != nil {
+		h.t.Fatalf("Failed to start program: %v", err)
+	}
+
+	h.program = program
+	h.t.Cleanup(program.stop)
+	h.program.Settle()
+	h.program.Draw()
+}
+
+func writeTree(t *testing.T, root string, tree map[string]string) {
+	t.Helper()
+
+	for name, contents := range tree {
+		path := filepath.Join(root, filepath.FromSlash(name))
+
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Type feeds a scripted key sequence to the program the same way Run would,
+// redrawing after every key. Work started in the background by a key is
+// waited for before the next key is typed. The script is written like a key
+// sequence in the keymap, for example "jjl/foo<CR>". See ParseKeySequence.
+func (h *harness) Type(script string) {
+	h.t.Helper()
+
+	seq, err := ParseKeySequence(script)
+	if err != nil {
+		h.t.Fatalf("Invalid script %q: %v", script, err)
+	}
+
+	for _, stroke := range seq {
+		if h.program.Done() {
+			h.t.Fatalf("Key %v was typed after the program quit", stroke)
+		}
+
+		key := EventKey{Key: stroke.Key, Rune: stroke.Rune}
+		if stroke.Alt {
+			key.Mod = tcell.ModAlt
+		}
+
+		h.program.Dispatch(key)
+		h.program.Settle()
+		if !h.program.Done() {
+			h.program.Draw()
+		}
+	}
+}
+
+// Rows returns the text on the screen, one string per row, with trailing
+// spaces removed and the temporary root replaced by "<root>".
+func (h *harness) Rows() []string {
+	cells, w, height := h.screen.GetContents()
+	result := make([]string, 0, height)
+
+	for y := range height {
+		var sb strings.Builder
+		for x := 0; x < w; x++ {
+			cell := cells[y*w+x]
+			if len(cell.Runes) == 0 {
+				sb.WriteRune(' ')
+				continue
+			}
+
+			sb.WriteString(string(cell.Runes))
+		}
+
+		row := strings.TrimRight(sb.String(), " ")
+		result = append(result, strings.ReplaceAll(row, h.root, "<root>"))
+	}
+
+	return result
+}
+
+// Find returns the position of the first occurrence of text on the screen. The
+// temporary root isn't replaced here, so text shouldn't contain "<root>".
+func (h *harness) Find(text string) (x, y int, ok bool) {
+	cells, w, height := h.screen.GetContents()
+
+	for y := range height {
+		row := []rune{}
+		for x := 0; x < w; x++ {
+			if r := cells[y*w+x].Runes; len(r) > 0 {
+				row = append(row, r[0])
+			} else {
+				row = append(row, ' ')
+			}
+		}
+
+		if idx := strings.Index(string(row), text); idx != -1 {
+			return len([]rune(string(row)[:idx])), y, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// StyleAt returns the style of the cell at the given position.
+func (h *harness) StyleAt(x, y int) tcell.Style {
+	cells, w, _ := h.screen.GetContents()
+	return cells[y*w+x].Style
+}
+
+// AssertShows fails the test if text isn't anywhere on the screen.
+func (h *harness) AssertShows(text string) {
+	h.t.Helper()
+
+	if !strings.Contains(strings.Join(h.Rows(), "\n"), text) {
+		h.t.Errorf("want %q on screen, got:\n%s", text, strings.Join(h.Rows(), "\n"))
+	}
+}
+
+// WaitUntil dispatches what the program reports from the background until
+// done returns true. Unlike Type, it also picks up reports that aren't started
+// by a key press, such as changes to the watched directories.
+func (h *harness) WaitUntil(what string, done func() bool) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h.program.Settle()
+		h.program.Draw()
+
+		if done() {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("want %s, got:\n%s", what, strings.Join(h.Rows(), "\n"))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// WaitFor waits until text shows up on the screen. See WaitUntil.
+func (h *harness) WaitFor(text string) {
+	h.t.Helper()
+
+	h.WaitUntil(fmt.Sprintf("%q on screen", text), func() bool {
+		_, _, ok := h.Find(text)
+		return ok
+	})
+}
+
+// AssertSelected fails the test if the row showing name isn't drawn as the
+// selected entry.
+func (h *harness) AssertSelected(name string) {
+	h.t.Helper()
+
+	x, y, ok := h.Find(name)
+	if !ok {
+		h.t.Errorf("want %q on screen, got:\n%s", name, strings.Join(h.Rows(), "\n"))
+		return
+	}
+
+	if got := h.StyleAt(x, y); got != StyleSelectedEntry {
+		h.t.Errorf("want %q to be selected, got style=%v", name, got)
+	}
+}
+
+// AssertGolden compares the screen with testdata/<name>.golden. Run the tests
+// with -update to rewrite the golden files.
+func (h *harness) AssertGolden(name string) {
+	h.t.Helper()
+
+	got := strings.Join(h.Rows(), "\n") + "\n"
+	path := filepath.Join("testdata", name+".golden")
+
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			h.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		h.t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+	}
+
+	if got != string(want) {
+		h.t.Errorf("screen doesn't match %s\nwant:\n%s\ngot:\n%s", path, want, got)
+	}
+}
+
+// AssertPrinted fails the test unless the program has quit and would print
+// the given path relative to the root.
+func (h *harness) AssertPrinted(relPath string) {
+	h.t.Helper()
+
+	if !h.program.Done() {
+		h.t.Fatalf("want the program to have quit")
+	}
+
+	want := []string{filepath.Join(h.root, filepath.FromSlash(relPath))}
+	if got := h.program.PathsToPrint(); !slices.Equal(got, want) {
+		h.t.Errorf("want=%q printed, got=%q", want, got)
+	}
+}
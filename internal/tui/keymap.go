@@ -0,0 +1,403 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Keymap maps key sequences to actions for every mode that supports them. A
+// sequence can be a single key such as "j" or "<C-d>" or a chord of any length
+// such as "gg" or "<C-x><C-f>".
+//
+// Keys are written the same way as in Vim: plain characters stand for
+// themselves and special keys are written in angle brackets. See
+// ParseKeySequence for the list of supported names.
+type Keymap struct {
+	// Keyed by the canonical form of each sequence, see FormatKeySequence.
+	bindings map[Mode]map[string]Action
+}
+
+var defaultBindings = map[Mode]map[string]Action{
+	ModeDefault: {
+		"j":       ActionMoveDown,
+		"k":       ActionMoveUp,
+		"h":       ActionParentDir,
+		"l":       ActionEnterDir,
+		".":       ActionToggleHidden,
+		"I":       ActionToggleIgnore,
+		"i":       ActionToggleInfo,
+		"s":       ActionCycleSort,
+		"S":       ActionReverseSort,
+		"/":       ActionStartSearch,
+		"<C-f>":   ActionStartFinder,
+		"m":       ActionSetMark,
+		"'":       ActionJumpToMark,
+		"M":       ActionManageMarks,
+		"gg":      ActionGoToTop,
+		"G":       ActionGoToBottom,
+		"<C-d>":   ActionPageDown,
+		"<C-u>":   ActionPageUp,
+		"q":       ActionQuit,
+		"<Space>": ActionToggleSelect,
+		"V":       ActionVisualSelect,
+		"u":       ActionClearSelection,
+		"P":       ActionQuitWithSelection,
+		"<C-o>":   ActionHistoryBack,
+		"<Tab>":   ActionHistoryForward,
+		"H":       ActionShowHistory,
+	},
+	ModeSearch: {
+		"<CR>":    ActionSearchAccept,
+		"<Esc>":   ActionSearchCancel,
+		"<Tab>":   ActionSearchEnterDir,
+		"<S-Tab>": ActionSearchParentDir,
+		"<BS>":    ActionSearchDeleteChar,
+	},
+	ModeMarkManager: {
+		"j":      ActionMarksDown,
+		"k":      ActionMarksUp,
+		"<Down>": ActionMarksDown,
+		"<Up>":   ActionMarksUp,
+		"l":      ActionMarksJump,
+		"<CR>":   ActionMarksJump,
+		"d":      ActionMarksDelete,
+		"r":      ActionMarksRename,
+		"p":      ActionMarksRepoint,
+		"q":      ActionMarksClose,
+		"<Esc>":  ActionMarksClose,
+	},
+	ModeFinder: {
+		"<CR>":   ActionFinderAccept,
+		"<Esc>":  ActionFinderCancel,
+		"<C-n>":  ActionFinderDown,
+		"<Down>": ActionFinderDown,
+		"<C-p>":  ActionFinderUp,
+		"<Up>":   ActionFinderUp,
+		"<BS>":   ActionFinderDeleteChar,
+	},
+	ModeHistory: {
+		"j":      ActionHistoryDown,
+		"k":      ActionHistoryUp,
+		"<Down>": ActionHistoryDown,
+		"<Up>":   ActionHistoryUp,
+		"l":      ActionHistoryJump,
+		"<CR>":   ActionHistoryJump,
+		"q":      ActionHistoryClose,
+		"<Esc>":  ActionHistoryClose,
+	},
+}
+
+// DefaultKeymap returns the Vi-like keymap used when nothing is configured.
+func DefaultKeymap() *Keymap {
+	k := &Keymap{bindings: make(map[Mode]map[string]Action)}
+
+	for mode, bindings := range defaultBindings {
+		for keys, action := range bindings {
+			if err := k.Bind(mode, keys, action); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return k
+}
+
+// NewKeymap returns the default keymap with the given bindings applied on top
+// of it. The outer map is keyed by mode name ("default", "search", "marks",
+// "finder" or "history"), the inner one maps key sequences to action names. An
+// empty action name removes the binding.
+func NewKeymap(overrides map[string]map[string]string) (*Keymap, error) {
+	k := DefaultKeymap()
+
+	for modeName, bindings := range overrides {
+		mode, ok := modesByName[modeName]
+		if !ok {
+			return nil, fmt.Errorf("keymap: unknown mode %q", modeName)
+		}
+
+		for keys, actionName := range bindings {
+			var err error
+			if actionName == "" {
+				err = k.Unbind(mode, keys)
+			} else {
+				err = k.Bind(mode, keys, Action(actionName))
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("keymap: %s mode: %w", modeName, err)
+			}
+		}
+	}
+
+	return k, nil
+}
+
+// Bind maps keys to action in the given mode, replacing whatever keys were
+// mapped to before.
+func (k *Keymap) Bind(mode Mode, keys string, action Action) error {
+	spec, ok := Actions[action]
+	if !ok {
+		return fmt.Errorf("unknown action %q", action)
+	}
+	if spec.Mode != mode {
+		return fmt.Errorf("action %q can't be used in %s mode", action, mode)
+	}
+
+	seq, err := ParseKeySequence(keys)
+	if err != nil {
+		return err
+	}
+
+	if k.bindings[mode] == nil {
+		k.bindings[mode] = make(map[string]Action)
+	}
+	k.bindings[mode][FormatKeySequence(seq)] = action
+
+	return nil
+}
+
+// Unbind removes the mapping for keys in the given mode.
+func (k *Keymap) Unbind(mode Mode, keys string) error {
+	seq, err := ParseKeySequence(keys)
+	if err != nil {
+		return err
+	}
+
+	delete(k.bindings[mode], FormatKeySequence(seq))
+
+	return nil
+}
+
+// Bindings returns the key sequences mapped to action in the given mode,
+// sorted.
+func (k *Keymap) Bindings(mode Mode, action Action) []string {
+	result := []string{}
+	for keys, a := range k.bindings[mode] {
+		if a == action {
+			result = append(result, keys)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// lookup returns the action bound to seq, if there is one, and whether seq is
+// the beginning of a longer binding.
+func (k *Keymap) lookup(mode Mode, seq []KeyStroke) (action Action, found bool, isPrefix bool) {
+	keys := FormatKeySequence(seq)
+
+	for bound, a := range k.bindings[mode] {
+		if bound == keys {
+			action = a
+			found = true
+		} else if strings.HasPrefix(bound, keys) {
+			isPrefix = true
+		}
+	}
+
+	return action, found, isPrefix
+}
+
+// KeyStroke is a single key press in a normalized form. For runes, Key is
+// tcell.KeyRune. Otherwise, Rune is zero.
+type KeyStroke struct {
+	Key  tcell.Key
+	Rune rune
+	Alt  bool
+}
+
+// NewKeyStroke normalizes a key event.
+func NewKeyStroke(ev EventKey) KeyStroke {
+	stroke := KeyStroke{Key: ev.Key, Alt: ev.Mod&tcell.ModAlt != 0}
+
+	switch ev.Key {
+	case tcell.KeyRune:
+		stroke.Rune = ev.Rune
+	case tcell.KeyBackspace:
+		// Terminals disagree on what backspace sends.
+		stroke.Key = tcell.KeyBackspace2
+	}
+
+	return stroke
+}
+
+var keyNames = map[tcell.Key]string{
+	tcell.KeyCR:         "CR",
+	tcell.KeyESC:        "Esc",
+	tcell.KeyTAB:        "Tab",
+	tcell.KeyBacktab:    "S-Tab",
+	tcell.KeyBackspace2: "BS",
+	tcell.KeyUp:         "Up",
+	tcell.KeyDown:       "Down",
+	tcell.KeyLeft:       "Left",
+	tcell.KeyRight:      "Right",
+	tcell.KeyHome:       "Home",
+	tcell.KeyEnd:        "End",
+	tcell.KeyPgUp:       "PageUp",
+	tcell.KeyPgDn:       "PageDown",
+	tcell.KeyInsert:     "Insert",
+	tcell.KeyDelete:     "Del",
+	tcell.KeyF1:         "F1",
+	tcell.KeyF2:         "F2",
+	tcell.KeyF3:         "F3",
+	tcell.KeyF4:         "F4",
+	tcell.KeyF5:         "F5",
+	tcell.KeyF6:         "F6",
+	tcell.KeyF7:         "F7",
+	tcell.KeyF8:         "F8",
+	tcell.KeyF9:         "F9",
+	tcell.KeyF10:        "F10",
+	tcell.KeyF11:        "F11",
+	tcell.KeyF12:        "F12",
+}
+
+var runeNames = map[rune]string{
+	'<': "lt",
+	' ': "Space",
+}
+
+// Alternative spellings accepted by ParseKeySequence, lowercased.
+var keyAliases = map[string]string{
+	"enter":     "cr",
+	"return":    "cr",
+	"escape":    "esc",
+	"backspace": "bs",
+	"pgup":      "pageup",
+	"pgdn":      "pagedown",
+	"delete":    "del",
+	"backtab":   "s-tab",
+}
+
+// String returns the canonical name of the key stroke.
+func (k KeyStroke) String() string {
+	name := ""
+
+	switch {
+	case k.Key == tcell.KeyRune:
+		if n, ok := runeNames[k.Rune]; ok {
+			name = n
+		} else {
+			name = string(k.Rune)
+		}
+	case k.Key >= tcell.KeyCtrlA && k.Key <= tcell.KeyCtrlZ && keyNames[k.Key] == "":
+		name = "C-" + string(rune('a'+k.Key-tcell.KeyCtrlA))
+	default:
+		name = keyNames[k.Key]
+		if name == "" {
+			name = fmt.Sprintf("Key%d", k.Key)
+		}
+	}
+
+	if k.Alt {
+		return "<M-" + name + ">"
+	}
+	if k.Key == tcell.KeyRune && runeNames[k.Rune] == "" {
+		return name
+	}
+
+	return "<" + name + ">"
+}
+
+// FormatKeySequence returns the canonical form of seq.
+func FormatKeySequence(seq []KeyStroke) string {
+	var sb strings.Builder
+	for _, k := range seq {
+		sb.WriteString(k.String())
+	}
+
+	return sb.String()
+}
+
+// ParseKeySequence parses key sequences like "gg", "<C-d>" or "<C-x><C-f>".
+// Special keys are written in angle brackets and are case-insensitive: <CR>,
+// <Esc>, <Tab>, <S-Tab>, <BS>, <Space>, <lt>, <Up>, <Down>, <Left>, <Right>,
+// <Home>, <End>, <PageUp>, <PageDown>, <Insert>, <Del>, <F1> to <F12>,
+// <C-a> to <C-z> for control keys and <M-...> for anything pressed with Alt.
+func ParseKeySequence(keys string) ([]KeyStroke, error) {
+	if keys == "" {
+		return nil, fmt.Errorf("empty key sequence")
+	}
+
+	result := []KeyStroke{}
+
+	for len(keys) > 0 {
+		if !strings.HasPrefix(keys, "<") || !strings.Contains(keys, ">") || keys == "<>" {
+			r, size := utf8.DecodeRuneInString(keys)
+			result = append(result, KeyStroke{Key: tcell.KeyRune, Rune: r})
+			keys = keys[size:]
+			continue
+		}
+
+		end := strings.Index(keys, ">")
+		if end == 1 {
+			// A literal "<" followed by ">".
+			result = append(result, KeyStroke{Key: tcell.KeyRune, Rune: '<'})
+			keys = keys[1:]
+			continue
+		}
+
+		stroke, err := parseKeyName(keys[1:end])
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, stroke)
+		keys = keys[end+1:]
+	}
+
+	return result, nil
+}
+
+func parseKeyName(name string) (KeyStroke, error) {
+	lower := strings.ToLower(name)
+
+	if strings.HasPrefix(lower, "m-") || strings.HasPrefix(lower, "a-") {
+		rest := name[2:]
+		if utf8.RuneCountInString(rest) == 1 {
+			r, _ := utf8.DecodeRuneInString(rest)
+			return KeyStroke{Key: tcell.KeyRune, Rune: r, Alt: true}, nil
+		}
+
+		stroke, err := parseKeyName(rest)
+		stroke.Alt = true
+		return stroke, err
+	}
+
+	if alias, ok := keyAliases[lower]; ok {
+		lower = alias
+	}
+
+	for r, n := range runeNames {
+		if strings.ToLower(n) == lower {
+			return KeyStroke{Key: tcell.KeyRune, Rune: r}, nil
+		}
+	}
+
+	for k, n := range keyNames {
+		if strings.ToLower(n) == lower {
+			return KeyStroke{Key: k}, nil
+		}
+	}
+
+	if strings.HasPrefix(lower, "c-") && utf8.RuneCountInString(lower) == 3 {
+		r := rune(lower[2])
+		if r >= 'a' && r <= 'z' {
+			return NewKeyStroke(EventKey{Key: tcell.KeyCtrlA + tcell.Key(r-'a')}), nil
+		}
+	}
+
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		if !unicode.IsSpace(r) {
+			return KeyStroke{Key: tcell.KeyRune, Rune: r}, nil
+		}
+	}
+
+	return KeyStroke{}, fmt.Errorf("unknown key %q", "<"+name+">")
+}
@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseKeySequence(t *testing.T) {
+	data := []struct {
+		Keys string
+		Want string
+	}{
+		{"j", "j"},
+		{"gg", "gg"},
+		{"<C-d>", "<C-d>"},
+		{"<c-D>", "<C-d>"},
+		{"<Enter>", "<CR>"},
+		{"<C-m>", "<CR>"},
+		{"<C-i>", "<Tab>"},
+		{"<Backspace>", "<BS>"},
+		{"<C-x><C-f>", "<C-x><C-f>"},
+		{"<lt>", "<lt>"},
+		{"<", "<lt>"},
+		{"<>", "<lt>>"},
+		{"<Space>", "<Space>"},
+		{" ", "<Space>"},
+		{"<M-j>", "<M-j>"},
+		{"<A-Down>", "<M-Down>"},
+		{"<pgdn>", "<PageDown>"},
+		{"ä", "ä"},
+	}
+
+	for _, tt := range data {
+		seq, err := ParseKeySequence(tt.Keys)
+		if err != nil {
+			t.Errorf("keys=%q: unexpected error: %v", tt.Keys, err)
+			continue
+		}
+
+		if got := FormatKeySequence(seq); got != tt.Want {
+			t.Errorf("keys=%q: want=%q, got=%q", tt.Keys, tt.Want, got)
+		}
+	}
+
+	for _, keys := range []string{"", "<Nope>", "<C-1>"} {
+		if _, err := ParseKeySequence(keys); err == nil {
+			t.Errorf("keys=%q: want an error", keys)
+		}
+	}
+}
+
+func TestNewKeymap(t *testing.T) {
+	keymap, err := NewKeymap(map[string]map[string]string{
+		"default": {
+			"<Down>": "move-down",
+			"j":      "",
+		},
+		"search": {
+			"<C-g>": "search-cancel",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := keymap.Bindings(ModeDefault, ActionMoveDown); len(got) != 1 || got[0] != "<Down>" {
+		t.Errorf("want move-down bound to <Down> only, got=%v", got)
+	}
+	if got := keymap.Bindings(ModeSearch, ActionSearchCancel); len(got) != 2 {
+		t.Errorf("want search-cancel bound to <Esc> and <C-g>, got=%v", got)
+	}
+
+	invalid := []map[string]map[string]string{
+		{"visual": {"j": "move-down"}},
+		{"default": {"j": "fly"}},
+		{"default": {"j": "search-cancel"}},
+		{"default": {"<Nope>": "move-down"}},
+	}
+
+	for _, overrides := range invalid {
+		if _, err := NewKeymap(overrides); err == nil {
+			t.Errorf("overrides=%v: want an error", overrides)
+		}
+	}
+}
+
+func TestResolveKeys(t *testing.T) {
+	keymap := DefaultKeymap()
+	for keys, action := range map[string]Action{
+		"<C-x><C-x>j": ActionGoToBottom,
+		"z":           ActionGoToTop,
+		"zj":          ActionPageDown,
+	} {
+		if err := keymap.Bind(ModeDefault, keys, action); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctrlX := EventKey{Key: tcell.KeyCtrlX}
+
+	data := []struct {
+		Keys []EventKey
+		Want []Action
+	}{
+		{[]EventKey{runeKey('j')}, []Action{ActionMoveDown}},
+		{[]EventKey{runeKey('g'), runeKey('g')}, []Action{ActionGoToTop}},
+		{[]EventKey{runeKey('g'), runeKey('j')}, []Action{ActionMoveDown}},
+		{[]EventKey{ctrlX, ctrlX, runeKey('j')}, []Action{ActionGoToBottom}},
+		{[]EventKey{ctrlX, runeKey('j')}, []Action{ActionMoveDown}},
+		{[]EventKey{runeKey('z'), runeKey('j')}, []Action{ActionPageDown}},
+		{[]EventKey{runeKey('z'), runeKey('k')}, []Action{ActionGoToTop, ActionMoveUp}},
+		{[]EventKey{runeKey('x')}, []Action{}},
+	}
+
+	for _, tt := range data {
+		s := NewState("/tmp", false, nil, keymap)
+		got := []Action{}
+
+		for _, key := range tt.Keys {
+			var actions []Action
+			s, actions = resolveKeys(s, key)
+			got = append(got, actions...)
+		}
+
+		if FormatKeySequence(s.PendingKeys) != "" {
+			t.Errorf("keys=%v: want no pending keys, got=%v", tt.Keys, s.PendingKeys)
+		}
+		if len(got) != len(tt.Want) {
+			t.Errorf("keys=%v: want=%v, got=%v", tt.Keys, tt.Want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.Want[i] {
+				t.Errorf("keys=%v: want=%v, got=%v", tt.Keys, tt.Want, got)
+			}
+		}
+	}
+}
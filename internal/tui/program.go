@@ -0,0 +1,1105 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/bnuredini/pathsurfer/internal/conf"
+	"github.com/bnuredini/pathsurfer/internal/dircache"
+	"github.com/bnuredini/pathsurfer/internal/fileinfo"
+	"github.com/bnuredini/pathsurfer/internal/frecency"
+	"github.com/bnuredini/pathsurfer/internal/gitstatus"
+	"github.com/bnuredini/pathsurfer/internal/history"
+	"github.com/bnuredini/pathsurfer/internal/ignore"
+	"github.com/bnuredini/pathsurfer/internal/marks"
+	"github.com/bnuredini/pathsurfer/internal/positions"
+	"github.com/bnuredini/pathsurfer/internal/preview"
+	"github.com/bnuredini/pathsurfer/internal/sorting"
+	"github.com/bnuredini/pathsurfer/internal/walk"
+	"github.com/bnuredini/pathsurfer/internal/watch"
+)
+
+// dirBatchSize is how many entries are read from a directory at a time.
+const dirBatchSize = 512
+
+// dirProgressInterval is how often a listing that's still being read is
+// passed on, so that huge directories fill in bit by bit.
+const dirProgressInterval = 100 * time.Millisecond
+
+// dirTimeout is how long reading a directory may stall before it's given up
+// on, e.g. because a network mount stopped responding. It's a variable so that
+// tests can shorten it.
+var dirTimeout = 10 * time.Second
+
+// ErrDirTimeout is reported when reading a directory stalls for longer than
+// dirTimeout.
+var ErrDirTimeout = errors.New("timed out")
+
+// DirErrorKind says why a directory couldn't be listed.
+type DirErrorKind int
+
+const (
+	DirErrOther DirErrorKind = iota
+	DirErrPermission
+	DirErrNotDir
+	DirErrMissing
+	DirErrStale
+	DirErrTimeout
+)
+
+// DirError is reported in an EventDirLoaded when the directory at Path
+// couldn't be listed.
+type DirError struct {
+	Path string
+	Kind DirErrorKind
+	Err  error
+}
+
+// newDirError wraps err, which was returned while listing the directory at
+// path, in a DirError of the right kind.
+func newDirError(path string, err error) *DirError {
+	kind := DirErrOther
+
+	switch {
+	case errors.Is(err, ErrDirTimeout):
+		kind = DirErrTimeout
+	case errors.Is(err, fs.ErrPermission):
+		kind = DirErrPermission
+	case errors.Is(err, syscall.ENOTDIR):
+		kind = DirErrNotDir
+	case errors.Is(err, fs.ErrNotExist):
+		kind = DirErrMissing
+	case errors.Is(err, syscall.ESTALE), errors.Is(err, syscall.ENOTCONN):
+		// ENOTCONN is what FUSE mounts return once the process serving them
+		// is gone.
+		kind = DirErrStale
+	}
+
+	return &DirError{Path: path, Kind: kind, Err: err}
+}
+
+func (e *DirError) Error() string {
+	return fmt.Sprintf("reading %s: %s", e.Path, e.Reason())
+}
+
+func (e *DirError) Unwrap() error {
+	return e.Err
+}
+
+// Reason returns a short description of what went wrong that fits in a pane.
+func (e *DirError) Reason() string {
+	switch e.Kind {
+	case DirErrPermission:
+		return "permission denied"
+	case DirErrNotDir:
+		return "not a directory"
+	case DirErrMissing:
+		return "doesn't exist"
+	case DirErrStale:
+		return "stale mount"
+	case DirErrTimeout:
+		return "timed out"
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(e.Err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+
+	return e.Err.Error()
+}
+
+// The listing cache holds at most this many directories and entries. The
+// least recently used listings are dropped first.
+const (
+	dirCacheListings = 256
+	dirCacheEntries  = 200_000
+)
+
+// dirCacheSettleTime is how long ago a directory must have been modified for
+// its listing to be cached. Modification times are only so precise, so a
+// directory that's modified right after it's been read could otherwise look
+// unchanged.
+const dirCacheSettleTime = 2 * time.Second
+
+// previewMaxLines is how many lines of a file are read for the preview. It's
+// more than fits on most screens so that previews don't need to be read again
+// when the terminal is resized.
+const previewMaxLines = 200
+
+// Program drives a State with events coming from a tcell screen, carries out
+// the effects returned by Update and draws the result with View.
+type Program struct {
+	screen tcell.Screen
+	config *conf.Config
+	logger *slog.Logger
+	marks  *marks.Store
+	ignore *ignore.Matcher
+
+	state        State
+	done         bool
+	cancelled    bool
+	pathsToPrint []string
+
+	// Directories that are changed into are added to the frecency database
+	// right away, in the background, so that they aren't lost if the process
+	// is killed. Sort orders are saved the same way. quit waits for recording
+	// to finish.
+	lastVisited string
+	recording   sync.WaitGroup
+
+	// sortSaves holds the newest pending save of the sort order of each
+	// directory, so that older ones that are still waiting are skipped.
+	sortMu    sync.Mutex
+	sortSeq   int
+	sortSaves map[string]int
+
+	// storedPositions are the cursor positions read at startup. Only the ones
+	// that changed since are saved when the program is done.
+	storedPositions map[string]string
+
+	// Work done in the background reports back by adding events to queue.
+	// Whenever it does, an interrupt is posted to the screen so that Run wakes
+	// up and dispatches them.
+	queueMu     sync.Mutex
+	queue       []Event
+	background  sync.WaitGroup
+	stopFinder  context.CancelFunc
+	stopGit     context.CancelFunc
+	stopUsage   context.CancelFunc
+	stopPreview context.CancelFunc
+	stopLoad    map[Pane]context.CancelFunc
+
+	// dirCache holds the listings of directories that have been read
+	// recently, shared by all panes.
+	dirCache *dircache.Cache
+
+	// watcher reports changes to the directories in the panes. It's nil if
+	// watching is turned off or isn't supported.
+	watcher *watch.Watcher
+
+	// usageCache holds the disk usage of directories that have been counted
+	// recently. It's written from background goroutines.
+	usageMu    sync.Mutex
+	usageCache map[string]cachedUsage
+}
+
+type cachedUsage struct {
+	usage     fileinfo.Usage
+	countedAt time.Time
+}
+
+// usageCacheTTL is how long the disk usage of a directory is reused before
+// it's counted again.
+const usageCacheTTL = time.Minute
+
+// NewProgram returns a program that starts in path. The screen must already be
+// initialized.
+func NewProgram(screen tcell.Screen, config *conf.Config, logger *slog.Logger, path string) (*Program, error) {
+	markStore := marks.NewStore(config.MarkFilePath)
+	storedMarks, err := markStore.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	keymap, err := NewKeymap(config.Keymap)
+	if err != nil {
+		return nil, err
+	}
+
+	syntaxStyles, err := NewSyntaxStyles(config.Syntax)
+	if err != nil {
+		return nil, err
+	}
+	StyleSyntax = syntaxStyles
+
+	// Enter picks in picker mode. It isn't bound otherwise so that a stray
+	// press doesn't quit, e.g. right after a search.
+	if config.Pick || config.PickDir || config.PickFile {
+		enter := []KeyStroke{{Key: tcell.KeyCR}}
+		if _, found, isPrefix := keymap.lookup(ModeDefault, enter); !found && !isPrefix {
+			if err := keymap.Bind(ModeDefault, "<CR>", ActionQuitWithSelection); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ignoreMatcher, err := ignore.New(config.IgnoreFilePath)
+	if err != nil {
+		logger.Error("Couldn't read the global ignore file", "path", config.IgnoreFilePath, "err", err)
+	}
+
+	defaultSort, err := sorting.ParseOrder(config.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	sortOrders := make(map[string]sorting.Order)
+	if config.SortFilePath != "" {
+		sortOrders, err = sorting.LoadOrders(config.SortFilePath)
+		if err != nil {
+			logger.Error("Couldn't read the sort orders", "path", config.SortFilePath, "err", err)
+		}
+	}
+
+	var storedHistory []history.Entry
+	if config.HistoryFilePath != "" {
+		storedHistory, err = history.Load(config.HistoryFilePath)
+		if err != nil {
+			logger.Error("Couldn't read the history", "path", config.HistoryFilePath, "err", err)
+		}
+	}
+
+	storedPositions := make(map[string]string)
+	if config.PositionFilePath != "" {
+		storedPositions, err = positions.Load(config.PositionFilePath)
+		if err != nil {
+			logger.Error("Couldn't read the cursor positions", "path", config.PositionFilePath, "err", err)
+		}
+	}
+
+	state := NewState(path, config.ShowHiddenFiles, storedMarks, keymap)
+	state = state.withStoredHistory(storedHistory)
+	state.PositionHistory = maps.Clone(storedPositions)
+	state.cursor = state.rememberedPosition(path)
+	state.RespectIgnore = config.RespectIgnore
+	state.ShowInfo = config.ShowInfo
+	state.DefaultSort = defaultSort
+	state.SortOrders = sortOrders
+	state.Prompt = config.Prompt
+
+	switch {
+	case config.PickDir:
+		state.Pick = PickDir
+	case config.PickFile:
+		state.Pick = PickFile
+	case config.Pick:
+		state.Pick = PickAny
+	}
+
+	p := &Program{
+		screen:          screen,
+		config:          config,
+		logger:          logger,
+		marks:           markStore,
+		ignore:          ignoreMatcher,
+		state:           state,
+		lastVisited:     path,
+		storedPositions: storedPositions,
+		dirCache:        dircache.New(dirCacheListings, dirCacheEntries),
+	}
+
+	if config.Watch {
+		p.watcher, err = watch.New(func(dirs []string) {
+			p.post(EventDirsChanged{Paths: dirs})
+		})
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			logger.Error("Couldn't watch directories for changes", "err", err)
+		}
+	}
+
+	w, h := screen.Size()
+	p.Dispatch(EventResize{Width: w, Height: h})
+	p.perform(Init(p.state))
+
+	return p, nil
+}
+
+// State returns the current state.
+func (p *Program) State() State {
+	return p.state
+}
+
+// Done reports whether an EffectQuit, an EffectQuitWithSelection or an
+// EffectCancel has been carried out.
+func (p *Program) Done() bool {
+	return p.done
+}
+
+// Cancelled reports whether the program stopped because of an EffectCancel.
+func (p *Program) Cancelled() bool {
+	return p.cancelled
+}
+
+// PathsToPrint returns the paths that should be printed once the program is
+// done. It's empty if nothing should be printed.
+func (p *Program) PathsToPrint() []string {
+	return p.pathsToPrint
+}
+
+// Run reads events from the screen until the user quits and returns the paths
+// that should be printed.
+func (p *Program) Run() []string {
+	p.Draw()
+
+	for !p.done {
+		switch ev := p.screen.PollEvent().(type) {
+		case nil:
+			// The screen has been finalized.
+			return p.pathsToPrint
+
+		case *tcell.EventResize:
+			p.screen.Sync()
+			w, h := ev.Size()
+			p.Dispatch(EventResize{Width: w, Height: h})
+
+		case *tcell.EventInterrupt:
+			if !p.dispatchQueued() {
+				continue
+			}
+
+		case *tcell.EventKey:
+			p.logger.Debug(
+				"Processing key press",
+				"keyRune", ev.Rune(),
+				"keyString", string(ev.Rune()),
+				"currMode", p.state.Mode,
+				"selectedIdx", p.state.SelectedIdx,
+			)
+			p.Dispatch(NewEventKey(ev))
+
+		default:
+			continue
+		}
+
+		if !p.done {
+			p.Draw()
+		}
+	}
+
+	return p.pathsToPrint
+}
+
+// Dispatch feeds ev to Update and carries out the resulting effects, feeding
+// their outcomes back in until nothing is left to do. It doesn't draw.
+func (p *Program) Dispatch(ev Event) {
+	switch ev := ev.(type) {
+	case EventDirLoaded:
+		p.recordVisit(ev)
+	case EventDirsChanged:
+		for _, path := range ev.Paths {
+			p.dirCache.Invalidate(path)
+		}
+	}
+
+	var effects []Effect
+	p.state, effects = Update(p.state, ev)
+	p.perform(effects)
+}
+
+// Settle waits for the work running in the background to finish and
+// dispatches the events it produced, until nothing is left. Run doesn't need
+// it; it's for driving the program without a terminal, e.g. in tests.
+func (p *Program) Settle() {
+	for {
+		p.background.Wait()
+		if !p.dispatchQueued() {
+			break
+		}
+	}
+
+	p.recording.Wait()
+}
+
+// post queues an event produced in the background. It's safe to call from any
+// goroutine.
+func (p *Program) post(ev Event) {
+	p.queueMu.Lock()
+	p.queue = append(p.queue, ev)
+	p.queueMu.Unlock()
+
+	// If the screen's event queue is full, there are interrupts waiting to be
+	// handled already.
+	_ = p.screen.PostEvent(tcell.NewEventInterrupt(nil))
+}
+
+// dispatchQueued dispatches the events queued so far and reports whether there
+// were any.
+func (p *Program) dispatchQueued() bool {
+	p.queueMu.Lock()
+	queued := p.queue
+	p.queue = nil
+	p.queueMu.Unlock()
+
+	for _, ev := range queued {
+		if p.done {
+			break
+		}
+		p.Dispatch(ev)
+	}
+
+	return len(queued) > 0
+}
+
+// Draw renders the current state and makes it visible.
+func (p *Program) Draw() {
+	View(p.state, p.screen)
+	p.screen.Show()
+}
+
+func (p *Program) perform(effects []Effect) {
+	// Listings found in the cache are dispatched once the other effects have
+	// been carried out, since those were worked out without them.
+	var cached []Event
+	defer func() {
+		for _, ev := range cached {
+			if p.done {
+				return
+			}
+			p.Dispatch(ev)
+		}
+	}()
+
+	for _, effect := range effects {
+		if p.done {
+			return
+		}
+
+		switch effect := effect.(type) {
+		case EffectLoadDir:
+			if ev, ok := p.loadDir(effect); ok {
+				cached = append(cached, ev)
+			}
+
+		case EffectStopLoadingDir:
+			p.cancelLoadDir(effect.Pane)
+
+		case EffectLoadPreview:
+			p.loadPreview(effect.Path)
+
+		case EffectLoadInfo:
+			p.loadInfo(effect.Path)
+
+		case EffectStopDiskUsage:
+			p.cancelDiskUsage()
+
+		case EffectLoadGitStatus:
+			p.loadGitStatus(effect.Path)
+
+		case EffectWatchDirs:
+			if p.watcher == nil {
+				break
+			}
+
+			if err := p.watcher.Set(effect.Paths); err != nil {
+				p.logger.Debug("Couldn't watch a directory", "err", err)
+			}
+
+		case EffectReloadIgnoreFiles:
+			p.ignore.Reset()
+			// Cached listings remember which entries were ignored.
+			p.dirCache.Clear()
+
+		case EffectSaveSortOrder:
+			p.saveSortOrder(effect)
+
+		case EffectStoreMark:
+			_, err := p.marks.Set(effect.Key, effect.Path)
+			if err != nil {
+				p.Dispatch(EventMarksLoaded{Err: fmt.Errorf("setting mark: %w", err)})
+				break
+			}
+
+			p.loadMarks()
+
+		case EffectLoadMarks:
+			p.loadMarks()
+
+		case EffectDeleteMark:
+			if err := p.marks.Delete(effect.Key); err != nil {
+				p.Dispatch(EventMarksLoaded{Err: fmt.Errorf("deleting mark: %w", err)})
+				break
+			}
+
+			p.loadMarks()
+
+		case EffectRenameMark:
+			if err := p.marks.Rename(effect.From, effect.To); err != nil {
+				p.Dispatch(EventMarksLoaded{Err: fmt.Errorf("renaming mark: %w", err)})
+				break
+			}
+
+			p.loadMarks()
+
+		case EffectStartFinder:
+			p.startFinder(effect)
+
+		case EffectStopFinder:
+			p.cancelFinder()
+
+		case EffectQuit:
+			paths := []string{}
+			if effect.Path != "" {
+				paths = append(paths, effect.Path)
+				p.recordVisits(effect.Path)
+			}
+
+			p.quit(paths)
+
+		case EffectQuitWithSelection:
+			p.quit(effect.Paths)
+
+		case EffectCancel:
+			p.cancelled = true
+			p.quit(nil)
+		}
+	}
+}
+
+// loadMarks reads every mark again, checks which of them point to directories
+// that are gone and dispatches the result.
+func (p *Program) loadMarks() {
+	storedMarks, err := p.marks.Load()
+	if err != nil {
+		p.Dispatch(EventMarksLoaded{Err: err})
+		return
+	}
+
+	missing := make(map[rune]bool)
+	for key, path := range storedMarks {
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			missing[key] = true
+		}
+	}
+
+	p.Dispatch(EventMarksLoaded{Marks: storedMarks, Missing: missing})
+}
+
+func (p *Program) startFinder(effect EffectStartFinder) {
+	p.cancelFinder()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopFinder = cancel
+
+	opts := walk.Options{
+		MaxDepth:   p.config.FinderMaxDepth,
+		Exclude:    p.config.FinderExcludePatterns(),
+		ShowHidden: effect.ShowHidden,
+	}
+	if effect.RespectIgnore {
+		opts.Ignored = p.ignore.Ignored
+	}
+
+	p.background.Add(1)
+	go func() {
+		defer p.background.Done()
+
+		start := time.Now()
+		found := 0
+
+		err := walk.Walk(ctx, effect.Root, opts, func(batch []walk.Entry) {
+			found += len(batch)
+			p.post(EventFinderResults{ID: effect.ID, Entries: batch})
+		})
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+
+		p.logger.Debug("Finished walking", "root", effect.Root, "found", found, "took", time.Since(start))
+		p.post(EventFinderResults{ID: effect.ID, Done: true, Err: err})
+	}()
+}
+
+// stop cancels everything running in the background and stops watching
+// directories.
+func (p *Program) stop() {
+	for pane := range p.stopLoad {
+		p.cancelLoadDir(pane)
+	}
+	p.cancelFinder()
+	p.cancelGitStatus()
+	p.cancelDiskUsage()
+	p.cancelPreview()
+
+	if p.watcher != nil {
+		if err := p.watcher.Close(); err != nil {
+			p.logger.Error("Couldn't stop watching directories", "err", err)
+		}
+	}
+}
+
+// quit stops everything running in the background, saves what's kept between
+// sessions and marks the program as done.
+func (p *Program) quit(pathsToPrint []string) {
+	p.stop()
+
+	p.done = true
+	p.pathsToPrint = pathsToPrint
+
+	if p.config.PositionFilePath != "" {
+		changed := make(map[string]string)
+		for path, name := range p.state.rememberPosition().PositionHistory {
+			if stored, ok := p.storedPositions[path]; !ok || stored != name {
+				changed[path] = name
+			}
+		}
+
+		if err := positions.Save(p.config.PositionFilePath, changed); err != nil {
+			p.logger.Error("Couldn't save the cursor positions", "path", p.config.PositionFilePath, "err", err)
+		}
+	}
+
+	if p.config.HistoryFilePath != "" {
+		// Whatever had been gone back from is dropped so that the next
+		// session starts at the newest directory.
+		stack, idx := p.state.historySoFar()
+		if err := history.Save(p.config.HistoryFilePath, stack[:idx+1]); err != nil {
+			p.logger.Error("Couldn't save the history", "path", p.config.HistoryFilePath, "err", err)
+		}
+	}
+
+	p.recording.Wait()
+}
+
+// recordVisit remembers that the user changed into the directory that ev
+// lists, unless they've moved on by the time it's been read.
+func (p *Program) recordVisit(ev EventDirLoaded) {
+	if ev.Pane != PaneCurrent || ev.Partial || ev.Err != nil {
+		return
+	}
+	if ev.Path != p.state.Path || ev.Path == p.lastVisited {
+		return
+	}
+
+	p.lastVisited = ev.Path
+	p.recordVisits(ev.Path)
+}
+
+// recordVisits adds visits to paths to the frecency database in the
+// background.
+func (p *Program) recordVisits(paths ...string) {
+	if p.config.FrecencyFilePath == "" {
+		return
+	}
+
+	now := time.Now()
+
+	p.recording.Add(1)
+	go func() {
+		defer p.recording.Done()
+
+		if err := frecency.Record(p.config.FrecencyFilePath, now, paths...); err != nil {
+			p.logger.Error("Couldn't update the frecency database", "err", err)
+		}
+	}()
+}
+
+// saveSortOrder records the order picked for a directory in the background.
+// If the order is changed again before it's been saved, only the newest one is
+// written.
+func (p *Program) saveSortOrder(effect EffectSaveSortOrder) {
+	if p.config.SortFilePath == "" {
+		return
+	}
+
+	defaultOrder := p.state.DefaultSort
+
+	p.sortMu.Lock()
+	p.sortSeq++
+	seq := p.sortSeq
+	if p.sortSaves == nil {
+		p.sortSaves = make(map[string]int)
+	}
+	p.sortSaves[effect.Path] = seq
+	p.sortMu.Unlock()
+
+	p.recording.Add(1)
+	go func() {
+		defer p.recording.Done()
+
+		p.sortMu.Lock()
+		defer p.sortMu.Unlock()
+
+		if p.sortSaves[effect.Path] != seq {
+			return
+		}
+		delete(p.sortSaves, effect.Path)
+
+		err := sorting.SaveOrder(p.config.SortFilePath, effect.Path, effect.Order, defaultOrder)
+		if err != nil {
+			p.logger.Error("Couldn't save the sort order", "path", effect.Path, "err", err)
+		}
+	}()
+}
+
+// dirBatch is a part of a directory read by readDir. The last one has done
+// set, along with err if reading failed, or unchanged if the cached listing is
+// still up to date. modTime is when the directory was last modified before it
+// was read and cacheable whether that was long enough ago to cache what was
+// read.
+type dirBatch struct {
+	entries   []fs.DirEntry
+	ignored   []string
+	modTime   time.Time
+	cacheable bool
+	done      bool
+	unchanged bool
+	err       error
+}
+
+// loadDir reads the directory for a pane in the background. What's been read
+// is reported with an EventDirLoaded every dirProgressInterval and once more
+// when it's done. A directory that's still being read for the same pane is
+// cancelled.
+//
+// If the directory has been read before, the cached listing is returned to be
+// dispatched right away and the directory is only read again if it has been
+// modified since. Nothing more is reported until that's done.
+func (p *Program) loadDir(effect EffectLoadDir) (EventDirLoaded, bool) {
+	p.cancelLoadDir(effect.Pane)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if p.stopLoad == nil {
+		p.stopLoad = make(map[Pane]context.CancelFunc)
+	}
+	p.stopLoad[effect.Pane] = cancel
+
+	// Reading can hang in a system call that can't be interrupted, so it's
+	// not waited for. Only the goroutine passing on what it reads is.
+	batches := make(chan dirBatch)
+	go p.readDir(ctx, effect.Path, batches)
+
+	cached, quiet := p.dirCache.Peek(effect.Path)
+
+	p.background.Add(1)
+	go func() {
+		defer p.background.Done()
+
+		ticker := time.NewTicker(dirProgressInterval)
+		defer ticker.Stop()
+		stalled := time.NewTimer(dirTimeout)
+		defer stalled.Stop()
+
+		entries := []fs.DirEntry{}
+		ignored := make(map[string]bool)
+		// Set even before anything has been read so that a directory that
+		// takes a while to open is reported as loading.
+		changed := true
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ticker.C:
+				if changed && !quiet {
+					p.post(EventDirLoaded{
+						Pane:    effect.Pane,
+						Path:    effect.Path,
+						Entries: slices.Clip(entries),
+						Ignored: maps.Clone(ignored),
+						Partial: true,
+					})
+					changed = false
+				}
+
+			case <-stalled.C:
+				cancel()
+				p.logger.Error("Reading a directory timed out", "path", effect.Path)
+				if quiet {
+					// The cached listing stays up rather than being
+					// replaced with what little was read.
+					return
+				}
+				p.post(EventDirLoaded{
+					Pane:    effect.Pane,
+					Path:    effect.Path,
+					Entries: entries,
+					Ignored: ignored,
+					Err:     newDirError(effect.Path, ErrDirTimeout),
+				})
+				return
+
+			case batch := <-batches:
+				entries = append(entries, batch.entries...)
+				for _, name := range batch.ignored {
+					ignored[name] = true
+				}
+				changed = true
+				stalled.Reset(dirTimeout)
+
+				if !batch.done {
+					continue
+				}
+
+				if batch.unchanged {
+					if !quiet {
+						p.post(EventDirLoaded{
+							Pane:    effect.Pane,
+							Path:    effect.Path,
+							Entries: cached.Entries,
+							Ignored: cached.Ignored,
+						})
+					}
+					return
+				}
+
+				var err error
+				if batch.err != nil {
+					p.logger.Error("Couldn't read directory", "path", effect.Path, "err", batch.err)
+					err = newDirError(effect.Path, batch.err)
+				}
+
+				if batch.err == nil && batch.cacheable {
+					p.dirCache.Put(effect.Path, dircache.Listing{
+						Entries: entries,
+						Ignored: ignored,
+						ModTime: batch.modTime,
+					})
+				}
+
+				p.post(EventDirLoaded{
+					Pane:    effect.Pane,
+					Path:    effect.Path,
+					Entries: entries,
+					Ignored: ignored,
+					Err:     err,
+				})
+				return
+			}
+		}
+	}()
+
+	return EventDirLoaded{
+		Pane:    effect.Pane,
+		Path:    effect.Path,
+		Entries: cached.Entries,
+		Ignored: cached.Ignored,
+	}, quiet
+}
+
+// readDir reads the directory at path and sends what it reads to batches bit
+// by bit until it's done or ctx is cancelled.
+func (p *Program) readDir(ctx context.Context, path string, batches chan<- dirBatch) {
+	send := func(batch dirBatch) bool {
+		select {
+		case batches <- batch:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		send(dirBatch{done: true, err: err})
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		send(dirBatch{done: true, err: err})
+		return
+	}
+
+	modTime := info.ModTime()
+	cacheable := time.Since(modTime) > dirCacheSettleTime
+	_, hit := p.dirCache.Get(path, modTime)
+	stats := p.dirCache.Stats()
+	p.logger.Debug(
+		"Looked up a directory listing",
+		"path", path,
+		"hit", hit,
+		"hits", stats.Hits,
+		"misses", stats.Misses,
+		"listings", stats.Listings,
+		"entries", stats.Entries,
+	)
+
+	if hit {
+		send(dirBatch{modTime: modTime, done: true, unchanged: true})
+		return
+	}
+
+	for {
+		entries, err := f.ReadDir(dirBatchSize)
+		batch := dirBatch{entries: entries, modTime: modTime, cacheable: cacheable}
+
+		for i, entry := range entries {
+			// Reading the info once here lets the listing be sorted by time
+			// or size without touching the file system again.
+			if info, err := entry.Info(); err == nil {
+				entries[i] = fs.FileInfoToDirEntry(info)
+			}
+
+			if p.ignore.Ignored(filepath.Join(path, entry.Name()), entry.IsDir()) {
+				batch.ignored = append(batch.ignored, entry.Name())
+			}
+		}
+
+		if err != nil {
+			batch.done = true
+			if err != io.EOF {
+				batch.err = err
+			}
+		}
+
+		if !send(batch) || batch.done {
+			return
+		}
+	}
+}
+
+func (p *Program) cancelLoadDir(pane Pane) {
+	if stop := p.stopLoad[pane]; stop != nil {
+		stop()
+		delete(p.stopLoad, pane)
+	}
+}
+
+// loadPreview reads the beginning of the file at path in the background. A
+// preview that's still being read for another file is cancelled, and its
+// result is dropped.
+func (p *Program) loadPreview(path string) {
+	p.cancelPreview()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopPreview = cancel
+
+	p.background.Add(1)
+	go func() {
+		defer p.background.Done()
+
+		result, err := preview.Load(path, previewMaxLines)
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.post(EventPreviewLoaded{Path: path, Preview: result, Err: err})
+	}()
+}
+
+func (p *Program) cancelPreview() {
+	if p.stopPreview != nil {
+		p.stopPreview()
+		p.stopPreview = nil
+	}
+}
+
+// loadInfo reports details about the entry at path in the background and, if
+// it's a directory, goes on to count what's below it unless that has been done
+// recently. Looking up who owns the entry can take a while, e.g. when the
+// user database is on the network, so none of it is done here.
+func (p *Program) loadInfo(path string) {
+	p.cancelDiskUsage()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopUsage = cancel
+
+	p.background.Add(1)
+	go func() {
+		defer p.background.Done()
+
+		info, err := fileinfo.Stat(path)
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.post(EventInfoLoaded{Path: path, Info: info, Err: err})
+		if err != nil || !info.Mode.IsDir() {
+			return
+		}
+
+		p.usageMu.Lock()
+		cached, ok := p.usageCache[path]
+		p.usageMu.Unlock()
+
+		if ok && time.Since(cached.countedAt) < usageCacheTTL {
+			p.post(EventDiskUsage{Path: path, Usage: cached.usage, Done: true})
+			return
+		}
+
+		usage, err := fileinfo.DiskUsage(ctx, path, func(usage fileinfo.Usage) {
+			p.post(EventDiskUsage{Path: path, Usage: usage})
+		})
+		if errors.Is(err, context.Canceled) {
+			return
+		} else if err != nil {
+			p.logger.Error("Couldn't count what's in a directory", "path", path, "err", err)
+		} else {
+			p.usageMu.Lock()
+			if p.usageCache == nil {
+				p.usageCache = make(map[string]cachedUsage)
+			}
+			p.usageCache[path] = cachedUsage{usage: usage, countedAt: time.Now()}
+			p.usageMu.Unlock()
+		}
+
+		p.post(EventDiskUsage{Path: path, Usage: usage, Done: true})
+	}()
+}
+
+func (p *Program) cancelDiskUsage() {
+	if p.stopUsage != nil {
+		p.stopUsage()
+		p.stopUsage = nil
+	}
+}
+
+// loadGitStatus runs git status in the background if path is in a work tree.
+// Finding the work tree walks up the file system, so that's done in the
+// background too. A status that's still being loaded for another path is
+// cancelled.
+func (p *Program) loadGitStatus(path string) {
+	p.cancelGitStatus()
+
+	if !p.config.GitStatus {
+		p.Dispatch(EventGitStatusLoaded{Path: path})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopGit = cancel
+
+	p.background.Add(1)
+	go func() {
+		defer p.background.Done()
+
+		root, ok := gitstatus.FindRoot(path)
+		if ctx.Err() != nil {
+			return
+		}
+		if !ok {
+			p.post(EventGitStatusLoaded{Path: path})
+			return
+		}
+
+		status, err := gitstatus.Load(ctx, root)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			p.logger.Error("Couldn't read the git status", "root", root, "err", err)
+		}
+
+		p.post(EventGitStatusLoaded{Path: path, Status: status})
+	}()
+}
+
+func (p *Program) cancelGitStatus() {
+	if p.stopGit != nil {
+		p.stopGit()
+		p.stopGit = nil
+	}
+}
+
+func (p *Program) cancelFinder() {
+	if p.stopFinder != nil {
+		p.stopFinder()
+		p.stopFinder = nil
+	}
+}
@@ -0,0 +1,926 @@
+package tui
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/bnuredini/pathsurfer/internal/frecency"
+	"github.com/bnuredini/pathsurfer/internal/highlight"
+	"github.com/bnuredini/pathsurfer/internal/marks"
+)
+
+var testTree = map[string]string{
+	"alpha/":            "",
+	"alpha/one.txt":     "1",
+	"alpha/two.txt":     "2",
+	"beta/":             "",
+	"beta/foo/":         "",
+	"beta/foo/deep.txt": "",
+	"beta/foobar.go":    "",
+	"beta/zeta/":        "",
+	"gamma.txt":         "",
+	".hidden/":          "",
+}
+
+func TestProgramLayout(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	h.AssertSelected("alpha")
+	h.AssertShows("one.txt")
+	h.AssertGolden("layout_root")
+
+	h.Type("jl")
+	h.AssertSelected("foo")
+	h.AssertShows("deep.txt")
+	h.AssertGolden("layout_nested")
+}
+
+func TestProgramSearch(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	h.Type("jl/foo")
+	h.AssertGolden("search_in_progress")
+
+	h.Type("<CR>")
+	h.AssertShows("searched: <root>/beta")
+
+	h.Type("l")
+	h.AssertShows("deep.txt")
+
+	h.Type("q")
+	h.AssertPrinted("beta/foo")
+}
+
+func TestProgramScripts(t *testing.T) {
+	data := []struct {
+		Script string
+		Want   string
+	}{
+		{"q", ""},
+		{"<C-c>", ""},
+		{"jl<TAB>q", "beta"},
+		{"jlhq", ""},
+		{"jl/zeta<TAB><ESC>q", "beta/zeta"},
+		{"jl/nothing<CR>jq", "beta"},
+		{"jl/zeta<ESC>jjq", "beta"},
+		{"l/<S-TAB><ESC>q", ""},
+		{"jjlq", ""},
+		{".kl<ESC>q", ".hidden"},
+	}
+
+	for _, tt := range data {
+		t.Run(tt.Script, func(t *testing.T) {
+			h := newHarness(t, testTree)
+			h.Type(tt.Script)
+			h.AssertPrinted(tt.Want)
+		})
+	}
+}
+
+func TestProgramHistory(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	h.Type("jljjhl")
+	h.AssertSelected("zeta")
+}
+
+func TestProgramRun(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	go func() {
+		for _, r := range "jlq" {
+			h.screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+		}
+	}()
+
+	if got, want := h.program.Run(), h.program.PathsToPrint(); !slices.Equal(got, want) {
+		t.Errorf("want=%q, got=%q", want, got)
+	}
+	h.AssertPrinted("beta")
+}
+
+func TestProgramRecordsVisits(t *testing.T) {
+	h := newHarness(t, testTree)
+	h.Type("lhjlq")
+
+	store, err := frecency.Load(h.config.FrecencyFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]float64{}
+	for _, entry := range store.Entries(time.Now()) {
+		got[entry.Path] = entry.Visits
+	}
+
+	want := map[string]float64{
+		h.root:                         1,
+		filepath.Join(h.root, "alpha"): 1,
+		filepath.Join(h.root, "beta"):  2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%v, got=%v", want, got)
+	}
+}
+
+func TestProgramRecordsVisitsBeforeQuitting(t *testing.T) {
+	h := newHarness(t, testTree)
+	// The program never quits, like when it's killed.
+	h.Type("lh")
+
+	store, err := frecency.Load(h.config.FrecencyFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, entry := range store.Entries(time.Now()) {
+		got = append(got, entry.Path)
+	}
+	slices.Sort(got)
+
+	want := []string{h.root, filepath.Join(h.root, "alpha")}
+	if !slices.Equal(got, want) {
+		t.Errorf("want=%v, got=%v", want, got)
+	}
+}
+
+func TestProgramCustomKeymap(t *testing.T) {
+	h := newHarness(t, testTree)
+	h.config.Keymap = map[string]map[string]string{
+		"default": {
+			"<Down>":  "move-down",
+			"<Right>": "enter-dir",
+			"<C-x>q":  "quit",
+			"q":       "",
+		},
+	}
+	h.start(h.root)
+
+	h.Type("<Down><Right>q")
+	h.AssertSelected("foo")
+
+	h.Type("<C-x>q")
+	h.AssertPrinted("beta")
+}
+
+func TestProgramSetMark(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	// The second mark for 'a' overwrites the first one.
+	h.Type("lma")
+	h.Type("hmahmb")
+
+	got, err := marks.NewStore(h.config.MarkFilePath).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[rune]string{
+		'a': h.root,
+		'b': filepath.Dir(h.root),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%q, got=%q", want, got)
+	}
+	if !reflect.DeepEqual(h.program.State().Marks, want) {
+		t.Errorf("want=%q in the state, got=%q", want, h.program.State().Marks)
+	}
+}
+
+func TestProgramJumpToMark(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	store := marks.NewStore(h.config.MarkFilePath)
+	for key, path := range map[rune]string{
+		'z': filepath.Join(h.root, "beta", "zeta"),
+		'a': filepath.Join(h.root, "alpha"),
+	} {
+		if _, err := store.Set(key, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.start(h.root)
+
+	h.Type("'<Esc>")
+	h.AssertShows("(j/k: up/down)")
+
+	h.Type("'x")
+	h.AssertShows(`jumping to mark: mark 'x' isn't set`)
+
+	h.Type("jj'a")
+	h.AssertShows("navigating: <root>/alpha")
+	h.AssertSelected("one.txt")
+
+	// The position in the directory that was jumped away from is remembered.
+	h.Type("h")
+	h.AssertSelected("gamma.txt")
+
+	h.Type("'zq")
+	h.AssertPrinted("beta/zeta")
+}
+
+func TestProgramMarkPopup(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	// The paths don't depend on the temporary root so that the size of the
+	// popup is always the same.
+	store := marks.NewStore(h.config.MarkFilePath)
+	for key, path := range map[rune]string{
+		'w': "/srv/www",
+		'p': "/home/user/projects",
+		'a': "/tmp/a",
+	} {
+		if _, err := store.Set(key, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.start(h.root)
+
+	h.Type("'")
+	h.AssertGolden("mark_popup")
+}
+
+func TestProgramMarkManager(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	store := marks.NewStore(h.config.MarkFilePath)
+	for key, path := range map[rune]string{
+		'a': filepath.Join(h.root, "alpha"),
+		'g': filepath.Join(h.root, "gone"),
+		'z': filepath.Join(h.root, "beta", "zeta"),
+	} {
+		if _, err := store.Set(key, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.start(h.root)
+
+	h.Type("M")
+	h.AssertGolden("mark_manager")
+
+	x, y, _ := h.Find("missing")
+	if got := h.StyleAt(x, y); got != StyleDeadMark {
+		t.Errorf("want the dead mark drawn in red, got style=%v", got)
+	}
+
+	// Dead marks can't be jumped to.
+	h.Type("j<CR>")
+	h.AssertShows("doesn't exist anymore")
+	if got := h.program.State().Mode; got != ModeMarkManager {
+		t.Errorf("want mode=%v, got=%v", ModeMarkManager, got)
+	}
+
+	h.Type("d")
+	h.AssertSelected("zeta")
+
+	h.Type("rb")
+	h.AssertSelected("zeta")
+
+	h.Type("ra")
+	h.AssertShows("renaming mark: mark already exists: a")
+
+	h.Type("p")
+
+	got, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[rune]string{
+		'a': filepath.Join(h.root, "alpha"),
+		'b': h.root,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%q, got=%q", want, got)
+	}
+
+	h.Type("k<CR>")
+	h.AssertShows("navigating: <root>/alpha")
+
+	h.Type("Mq")
+	h.AssertShows("(j/k: up/down) (l: enter)")
+}
+
+func TestProgramFinder(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	h.Type("<C-f>")
+	h.AssertShows("find: <root>/")
+	h.AssertShows("9/9")
+
+	h.Type("deep")
+	h.AssertGolden("finder")
+
+	// Files are shown selected in their directory.
+	h.Type("<CR>")
+	h.AssertShows("navigating: <root>/beta/foo")
+	h.AssertSelected("deep.txt")
+
+	h.Type("h<C-f>zeta<CR>")
+	h.AssertShows("navigating: <root>/beta/zeta")
+
+	h.Type("<C-f>alp<Esc>")
+	h.AssertShows("navigating: <root>/beta/zeta")
+
+	h.Type("<C-f>nothing")
+	h.AssertShows("0/0")
+	h.Type("<CR>")
+	h.AssertShows("navigating: <root>/beta/zeta")
+}
+
+func TestProgramFinderOptions(t *testing.T) {
+	h := newHarness(t, testTree)
+	h.config.FinderMaxDepth = 1
+	h.config.FinderExclude = "gamma.txt"
+	h.start(h.root)
+
+	h.Type("<C-f>")
+	h.AssertShows("2/2")
+
+	h.Type("<Esc>.<C-f>")
+	h.AssertShows("3/3")
+}
+
+func TestProgramIgnoreFiles(t *testing.T) {
+	h := newHarness(t, map[string]string{
+		".git/":             "",
+		".gitignore":        "build/\n*.log\n!keep.log\n",
+		".ignore":           "secret.txt\n",
+		"app.log":           "",
+		"keep.log":          "",
+		"build/out.bin":     "",
+		"main.go":           "",
+		"secret.txt":        "",
+		"src/build/gen.go":  "",
+		"src/handler.go":    "",
+		"src/debug.log":     "",
+		"src/notes/todo.md": "",
+	})
+	h.config.RespectIgnore = true
+	h.start(h.root)
+
+	for _, name := range []string{"app.log", "build", "secret.txt"} {
+		if _, _, ok := h.Find(name); ok {
+			t.Errorf("want %q to be ignored", name)
+		}
+	}
+	h.AssertShows("keep.log")
+
+	// The child pane is filtered as well.
+	h.Type("G")
+	h.AssertSelected("src")
+	if _, _, ok := h.Find("debug.log"); ok {
+		t.Errorf("want debug.log to be ignored in the child pane")
+	}
+
+	// So is the finder.
+	h.Type("<C-f>go")
+	h.AssertShows("2/")
+	h.AssertShows("src/handler.go")
+	h.Type("<Esc>")
+
+	h.Type("I")
+	h.AssertShows("app.log")
+	h.AssertShows("secret.txt")
+
+	h.Type("/app<CR>")
+	h.AssertSelected("app.log")
+}
+
+func TestProgramSortOrders(t *testing.T) {
+	h := newHarness(t, map[string]string{
+		"file1.txt":     "",
+		"file2.txt":     "",
+		"file10.txt":    "",
+		"zdir/a.txt":    "",
+		"zdir/a10.txt":  "",
+		"zdir/a9.txt":   "",
+		"big.bin":       strings.Repeat("x", 4096),
+		"other/file.go": "",
+	})
+
+	assertOrder := func(names ...string) {
+		t.Helper()
+
+		lastY := -1
+		for _, name := range names {
+			_, y, ok := h.Find(name)
+			if !ok {
+				t.Fatalf("%q isn't shown", name)
+			}
+			if y <= lastY {
+				t.Fatalf("want %q to be shown in the order %q:\n%s", name, names, strings.Join(h.Rows(), "\n"))
+			}
+			lastY = y
+		}
+	}
+
+	assertOrder("file1.txt", "file10.txt", "file2.txt")
+
+	// Natural order keeps the cursor on the same entry.
+	h.Type("jj")
+	h.AssertSelected("file10.txt")
+	h.Type("s")
+	assertOrder("file1.txt", "file2.txt", "file10.txt")
+	h.AssertSelected("file10.txt")
+	h.AssertShows("(sorted by natural)")
+
+	h.Type("S")
+	assertOrder("file10.txt", "file2.txt", "file1.txt")
+
+	// The order belongs to the directory it was picked in.
+	h.Type("/zdir<CR>l")
+	assertOrder("a.txt", "a10.txt", "a9.txt")
+	h.Type("h")
+
+	// It's remembered across runs.
+	h.start(h.root)
+	assertOrder("file10.txt", "file2.txt", "file1.txt")
+
+	// Picking the default order again forgets it.
+	h.Type("Sssss")
+	assertOrder("file1.txt", "file10.txt", "file2.txt")
+	if _, _, ok := h.Find("sorted by"); ok {
+		t.Errorf("want no sort note for the default order")
+	}
+
+	data, err := os.ReadFile(h.config.SortFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("want an empty sort file, got %q", data)
+	}
+
+	h.config.SortOrder = "size,dirs-first"
+	h.start(h.root)
+	assertOrder("other", "zdir", "big.bin", "file1.txt")
+}
+
+func TestProgramPreview(t *testing.T) {
+	h := newHarness(t, map[string]string{
+		"a.txt":   "first line\n\tindented\n",
+		"b.bin":   "\x00\x01\x02binary",
+		"c.empty": "",
+		"dir/":    "",
+	})
+
+	h.AssertSelected("a.txt")
+	h.AssertGolden("preview_text")
+
+	h.Type("j")
+	h.AssertShows("binary, 9 B")
+	h.AssertShows("0000  00 01")
+
+	// Directories still show their listing.
+	h.Type("gg")
+	h.AssertSelected("a.txt")
+	if err := os.Chmod(filepath.Join(h.root, "c.empty"), 0); err != nil {
+		t.Fatal(err)
+	}
+	h.Type("G")
+	h.AssertSelected("dir")
+	if _, _, ok := h.Find("first line"); ok {
+		t.Errorf("want the preview to be replaced by the listing of dir")
+	}
+
+	// Files that can't be read show why.
+	if os.Geteuid() != 0 {
+		h.Type("k")
+		h.AssertShows("permission")
+	}
+}
+
+func TestProgramPreviewHighlighting(t *testing.T) {
+	h := newHarness(t, map[string]string{
+		"main.go":   "package a // x\n",
+		"notes.txt": "package main\n",
+	})
+
+	x, y, ok := h.Find("package")
+	if !ok {
+		t.Fatalf("want a preview of main.go")
+	}
+	if got, want := h.StyleAt(x, y), StyleSyntax[highlight.KindKeyword]; got != want {
+		t.Errorf("want the keyword to be highlighted")
+	}
+
+	x, y, ok = h.Find("// x")
+	if !ok {
+		t.Fatalf("want the comment in the preview")
+	}
+	if got, want := h.StyleAt(x, y), StyleSyntax[highlight.KindComment]; got != want {
+		t.Errorf("want the comment to be highlighted")
+	}
+
+	// Files without a lexer are drawn as they are.
+	h.Type("j")
+	x, y, _ = h.Find("package")
+	if got := h.StyleAt(x, y); got != tcell.StyleDefault {
+		t.Errorf("want notes.txt not to be highlighted")
+	}
+}
+
+func TestProgramGitStatus(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	h := newHarness(t, map[string]string{
+		".gitignore":    "*.log\n",
+		"changed.txt":   "one",
+		"clean.txt":     "",
+		"src/staged.go": "",
+	})
+	git := func(args ...string) {
+		t.Helper()
+
+		cmd := exec.Command("git", append([]string{"-C", h.root}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	git("init", "-q", "-b", "trunk")
+	git("add", ".gitignore", "changed.txt", "clean.txt")
+	git("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+	git("add", "src")
+	writeTree(t, h.root, map[string]string{
+		"changed.txt": "two",
+		"new.txt":     "",
+		"debug.log":   "",
+	})
+
+	h.config.GitStatus = true
+	h.config.RespectIgnore = false
+	h.start(h.root)
+	h.AssertShows("[trunk]")
+
+	markerOf := func(name string) rune {
+		t.Helper()
+
+		_, y, ok := h.Find(name)
+		if !ok {
+			t.Fatalf("%q isn't shown", name)
+		}
+
+		cells, w, _ := h.screen.GetContents()
+		marker := cells[y*w+harnessWidth/6*4-1]
+		if len(marker.Runes) == 0 {
+			return ' '
+		}
+		return marker.Runes[0]
+	}
+
+	data := map[string]rune{
+		"changed.txt": 'M',
+		"clean.txt":   ' ',
+		"new.txt":     '?',
+		"debug.log":   '!',
+		"src":         '+',
+	}
+	for name, want := range data {
+		if got := markerOf(name); got != want {
+			t.Errorf("name=%q: want=%q, got=%q", name, want, got)
+		}
+	}
+
+	h.config.GitStatus = false
+	h.start(h.root)
+	if _, _, ok := h.Find("[trunk]"); ok {
+		t.Errorf("want no git status when it's turned off")
+	}
+}
+
+func TestProgramEntryInfo(t *testing.T) {
+	h := newHarness(t, map[string]string{
+		"a.txt":         "hello",
+		"dir/one.txt":   "123",
+		"dir/sub/two":   "4567",
+		"dir/sub/three": "",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(h.root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	h.start(h.root)
+
+	if _, _, ok := h.Find("-rw-"); ok {
+		t.Errorf("want no details until they're turned on")
+	}
+
+	h.Type("i")
+	h.AssertShows("-rw-")
+	h.AssertShows("  5 B")
+
+	h.Type("j")
+	h.AssertShows("drwx")
+	h.AssertShows("7 B in 3 files, 1 dirs")
+
+	h.Type("j")
+	h.AssertShows("-> a.txt")
+
+	h.Type("i")
+	if _, _, ok := h.Find("-> a.txt"); ok {
+		t.Errorf("want the details to be hidden again")
+	}
+}
+
+func TestProgramSelection(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	h.Type("<Space>")
+	h.AssertSelected("beta")
+	h.AssertShows("(1 selected)")
+
+	// The selection is kept in other directories.
+	h.Type("lVjj")
+	h.AssertShows("select range")
+	h.Type("V")
+	h.AssertShows("(4 selected)")
+
+	// Toggling an entry again removes it.
+	h.Type("k<Space>")
+	h.AssertShows("(3 selected)")
+
+	// Enter only picks in picker mode.
+	h.Type("<CR>")
+	if h.program.Done() {
+		t.Fatalf("want the program to keep running")
+	}
+
+	h.Type("P")
+	if !h.program.Done() {
+		t.Fatalf("want the program to have quit")
+	}
+
+	want := []string{
+		filepath.Join(h.root, "alpha"),
+		filepath.Join(h.root, "beta", "foo"),
+		filepath.Join(h.root, "beta", "zeta"),
+	}
+	if got := h.program.PathsToPrint(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%q, got=%q", want, got)
+	}
+
+	// Without a selection, the entry under the cursor is printed. The cursor
+	// starts out where it was when the root was left.
+	h.start(h.root)
+	h.AssertSelected("beta")
+	h.Type("<Space>u")
+	if _, _, ok := h.Find("selected)"); ok {
+		t.Errorf("want the selection to be cleared")
+	}
+	h.Type("kP")
+	h.AssertPrinted("beta")
+}
+
+func TestProgramPick(t *testing.T) {
+	h := newHarness(t, testTree)
+	h.config.PickFile = true
+	h.config.Prompt = "Pick a file:"
+	h.start(h.root)
+
+	h.AssertShows("Pick a file: <root>")
+	h.AssertShows("(enter: pick) (q: cancel)")
+
+	// Directories can be entered, but not picked.
+	h.Type("<CR>")
+	h.AssertShows("only files can be picked")
+	h.Type("<Space>")
+	h.AssertShows("only files can be picked")
+	if _, _, ok := h.Find("selected)"); ok {
+		t.Errorf("want nothing to be selected")
+	}
+
+	h.Type("lj<CR>")
+	h.AssertPrinted("alpha/two.txt")
+	if h.program.Cancelled() {
+		t.Errorf("want the program not to be cancelled")
+	}
+
+	// A visual range only selects what can be picked.
+	h.config.PickFile = false
+	h.config.PickDir = true
+	h.start(h.root)
+	h.Type("VjjV")
+	h.AssertShows("(2 selected)")
+
+	h.Type("q")
+	if !h.program.Done() || !h.program.Cancelled() {
+		t.Fatalf("want the program to have been cancelled")
+	}
+	if got := h.program.PathsToPrint(); len(got) != 0 {
+		t.Errorf("want nothing to be printed, got %q", got)
+	}
+
+	h.start(h.root)
+	h.Type("<C-c>")
+	if !h.program.Cancelled() {
+		t.Errorf("want Ctrl-C to cancel")
+	}
+}
+
+func TestProgramWatch(t *testing.T) {
+	h := newHarness(t, testTree)
+	h.config.Watch = true
+	h.start(h.root)
+
+	// The cursor stays on the same entry when others show up before it.
+	h.Type("jj")
+	h.AssertSelected("gamma.txt")
+	writeTree(t, h.root, map[string]string{"aaa.txt": "", "beta/new.txt": ""})
+	h.WaitFor("aaa.txt")
+	h.AssertSelected("gamma.txt")
+
+	// The parent and child panes are refreshed as well.
+	h.Type("k")
+	h.WaitFor("new.txt")
+	h.Type("l")
+	writeTree(t, h.root, map[string]string{"zzz/": ""})
+	h.WaitFor("zzz")
+
+	// A removed entry leaves the cursor on the same row.
+	h.Type("G")
+	h.AssertSelected("zeta")
+	if err := os.Remove(filepath.Join(h.root, "beta", "zeta")); err != nil {
+		t.Fatal(err)
+	}
+	h.WaitUntil("zeta to be gone", func() bool {
+		_, _, ok := h.Find("zeta")
+		return !ok
+	})
+	h.AssertSelected("new.txt")
+}
+
+func TestProgramDirCache(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	// Directories that have been modified just now aren't cached.
+	past := time.Now().Add(-time.Hour)
+	err := filepath.WalkDir(h.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return os.Chtimes(path, past, past)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.start(h.root)
+
+	// beta is read for the child pane and then found in the cache.
+	h.Type("jl")
+	h.AssertShows("deep.txt")
+	if stats := h.program.dirCache.Stats(); stats.Hits == 0 {
+		t.Errorf("want a cache hit, got %+v", stats)
+	}
+
+	// A modified directory is read again.
+	writeTree(t, h.root, map[string]string{"beta/new.txt": ""})
+	h.Type("hl")
+	h.AssertShows("new.txt")
+}
+
+func TestProgramRemembersPositions(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	h.Type("jljj")
+	h.AssertSelected("zeta")
+
+	// Positions are kept by name, so entries showing up in front of the
+	// remembered one don't move the cursor off it.
+	h.Type("hq")
+	writeTree(t, h.root, map[string]string{"beta/aaa/": "", ".hidden.txt": ""})
+	h.start(h.root)
+	h.AssertSelected("beta")
+	h.Type(".l")
+	h.AssertSelected("zeta")
+
+	// The directory that was quit in is remembered as well.
+	h.Type("kq")
+	h.start(filepath.Join(h.root, "beta"))
+	h.AssertSelected("foobar.go")
+}
+
+func TestProgramBackAndForward(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	h.Type("jll")
+	h.AssertShows("navigating: <root>/beta/foo")
+
+	h.Type("<C-o>")
+	h.AssertSelected("foo")
+	h.Type("<C-o>")
+	h.AssertSelected("beta")
+	h.Type("<Tab>")
+	h.AssertSelected("foo")
+
+	// The popup lists the newest directory first.
+	h.Type("H")
+	h.AssertShows("<root>/beta/foo")
+	h.AssertShows("* <root>/beta")
+	h.Type("j<CR>")
+	h.AssertSelected("beta")
+	h.Type("<Tab>")
+
+	// The history is kept for the next session, which starts at the newest
+	// directory.
+	h.Type("q")
+	h.start(h.root)
+	h.Type("<C-o>")
+	h.AssertSelected("foo")
+}
+
+func TestDirError(t *testing.T) {
+	data := []struct {
+		Err      error
+		Kind     DirErrorKind
+		WantText string
+	}{
+		{syscall.EACCES, DirErrPermission, "reading /x: permission denied"},
+		{syscall.ENOTDIR, DirErrNotDir, "reading /x: not a directory"},
+		{syscall.ENOENT, DirErrMissing, "reading /x: doesn't exist"},
+		{syscall.ESTALE, DirErrStale, "reading /x: stale mount"},
+		{syscall.ENOTCONN, DirErrStale, "reading /x: stale mount"},
+		{syscall.EIO, DirErrOther, "reading /x: " + syscall.EIO.Error()},
+	}
+
+	for _, tt := range data {
+		err := newDirError("/x", &fs.PathError{Op: "open", Path: "/x", Err: tt.Err})
+		if err.Kind != tt.Kind || err.Error() != tt.WantText {
+			t.Errorf("err=%v: want kind=%v %q, got kind=%v %q", tt.Err, tt.Kind, tt.WantText, err.Kind, err.Error())
+		}
+		if !errors.Is(err, tt.Err) {
+			t.Errorf("err=%v: want the error to be wrapped", tt.Err)
+		}
+	}
+}
+
+func TestProgramUnreadableDir(t *testing.T) {
+	h := newHarness(t, testTree)
+
+	// gamma.txt isn't a directory, which can only be found out by reading it.
+	h.start(filepath.Join(h.root, "gamma.txt"))
+	h.AssertShows("not a directory")
+	h.AssertShows("reading <root>/gamma.txt: not a directory")
+
+	// The mark still points to a directory that has since been replaced.
+	h.start(h.root)
+	h.Type("jlmxh")
+	beta := filepath.Join(h.root, "beta")
+	if err := os.RemoveAll(beta); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(beta, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Type("'x")
+	if got := h.program.State().Path; got != h.root {
+		t.Errorf("want to be back in the root, got %q", got)
+	}
+	h.AssertSelected("beta")
+	h.AssertShows("reading <root>/beta: not a directory")
+}
+
+func TestProgramDirTimeout(t *testing.T) {
+	if _, err := exec.LookPath("mkfifo"); err != nil {
+		t.Skip("mkfifo isn't installed")
+	}
+
+	h := newHarness(t, testTree)
+
+	// Opening a FIFO blocks until something opens it for writing, which is
+	// as good as a network mount that stopped responding.
+	fifo := filepath.Join(h.root, "stuck")
+	if out, err := exec.Command("mkfifo", fifo).CombinedOutput(); err != nil {
+		t.Fatalf("mkfifo: %v: %s", err, out)
+	}
+	t.Cleanup(func() {
+		// Lets the reader that's still waiting go.
+		if f, err := os.OpenFile(fifo, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			f.Close()
+		}
+	})
+
+	defer func(timeout time.Duration) { dirTimeout = timeout }(dirTimeout)
+	dirTimeout = 3 * dirProgressInterval
+
+	h.start(fifo)
+	h.AssertShows("reading <root>/stuck: timed out")
+	if _, _, ok := h.Find("loading…"); ok {
+		t.Errorf("want the loading note to be gone")
+	}
+}
@@ -0,0 +1,817 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bnuredini/pathsurfer/internal/fileinfo"
+	"github.com/bnuredini/pathsurfer/internal/gitstatus"
+	"github.com/bnuredini/pathsurfer/internal/highlight"
+	"github.com/bnuredini/pathsurfer/internal/history"
+	"github.com/bnuredini/pathsurfer/internal/preview"
+	"github.com/bnuredini/pathsurfer/internal/sorting"
+)
+
+type Mode int
+
+const (
+	ModeDefault Mode = iota
+	ModeSearch
+	ModeRecordingMark
+	ModeListeningForMark
+	ModeMarkManager
+	ModeFinder
+	ModeHistory
+)
+
+// Names of the modes whose keys can be configured.
+var modesByName = map[string]Mode{
+	"default": ModeDefault,
+	"search":  ModeSearch,
+	"marks":   ModeMarkManager,
+	"finder":  ModeFinder,
+	"history": ModeHistory,
+}
+
+func (m Mode) String() string {
+	switch m {
+	case ModeDefault:
+		return "default"
+	case ModeSearch:
+		return "search"
+	case ModeRecordingMark:
+		return "recording-mark"
+	case ModeListeningForMark:
+		return "listening-for-mark"
+	case ModeMarkManager:
+		return "marks"
+	case ModeFinder:
+		return "finder"
+	case ModeHistory:
+		return "history"
+	}
+
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+type SearchBarPrefix string
+
+const (
+	SearchBarPrefixSearching  = "searching"
+	SearchBarPrefixSearched   = "searched"
+	SearchBarPrefixNavigating = "navigating"
+)
+
+// Pane identifies one of the three file lists drawn side by side.
+type Pane int
+
+const (
+	PaneCurrent Pane = iota
+	PaneParent
+	PaneChild
+)
+
+const BigJumpLength = 22
+
+// PickMode says what the user is asked to pick. With PickNone, the navigator
+// is used for changing directories and quitting prints the current directory.
+type PickMode int
+
+const (
+	PickNone PickMode = iota
+	PickAny
+	PickDir
+	PickFile
+)
+
+// accepts reports whether an entry can be picked.
+func (m PickMode) accepts(isDir bool) bool {
+	switch m {
+	case PickDir:
+		return isDir
+	case PickFile:
+		return !isDir
+	}
+
+	return true
+}
+
+// errNotPickable returns the error shown when an entry that m doesn't accept
+// is picked.
+func (m PickMode) errNotPickable() error {
+	if m == PickDir {
+		return errors.New("only directories can be picked")
+	}
+
+	return errors.New("only files can be picked")
+}
+
+// State is everything the navigator knows about. It's only ever changed by
+// Update and only ever read by View, which means that it can be driven and
+// inspected without a terminal.
+type State struct {
+	Path            string
+	Mode            Mode
+	SearchEntry     string
+	SearchBarPrefix SearchBarPrefix
+	ShowHiddenFiles bool
+
+	// RespectIgnore hides the entries that are matched by ignore files such as
+	// .gitignore.
+	RespectIgnore bool
+
+	// SortOrders holds the orders picked for single directories. DefaultSort
+	// is used for every other directory.
+	DefaultSort sorting.Order
+	SortOrders  map[string]sorting.Order
+
+	// Entries is the listing of Path with hidden files filtered out (unless
+	// they are being shown). Files is what's displayed in the main pane. It's
+	// usually the same as Entries, but it gets narrowed down while searching.
+	Entries []fs.DirEntry
+	Files   []fs.DirEntry
+
+	// ParentPath and ChildPath are the directories whose listings are shown in
+	// the left and right panes. They're set as soon as a listing is requested
+	// so that the same directory isn't requested twice.
+	ParentPath  string
+	ParentFiles []fs.DirEntry
+	ChildPath   string
+	ChildFiles  []fs.DirEntry
+
+	// Loading holds the panes whose listings are still being read. Panes are
+	// only added once reading has taken a moment so that quick listings
+	// don't flicker.
+	Loading map[Pane]bool
+
+	// LoadErrs holds the panes whose listings couldn't be read. They show the
+	// error instead of the listing.
+	LoadErrs map[Pane]error
+
+	// PreviewPath is the file shown in the right pane instead of a listing
+	// when the selected entry isn't a directory. PreviewTokens holds the
+	// lines of the preview split into tokens if there's a lexer for the file.
+	PreviewPath   string
+	Preview       preview.Preview
+	PreviewTokens [][]highlight.Token
+	PreviewErr    error
+
+	// Selection holds the absolute paths of the entries picked with
+	// toggle-select and visual-select. It's kept when changing directories.
+	// While VisualDir is the current directory, the entries between the one
+	// named VisualStart and the cursor are about to be added to it.
+	Selection   map[string]bool
+	VisualDir   string
+	VisualStart string
+
+	// Pick is set when the navigator is used for picking entries. Only the
+	// entries it accepts can be selected. Prompt replaces the mode at the top
+	// of the screen if it's set.
+	Pick   PickMode
+	Prompt string
+
+	// ShowInfo turns on the line with details about the selected entry,
+	// which is at InfoPath. Usage is only set for directories, and it's
+	// still growing until UsageDone is set.
+	ShowInfo  bool
+	InfoPath  string
+	Info      fileinfo.Info
+	InfoErr   error
+	Usage     fileinfo.Usage
+	UsageDone bool
+
+	// Git is the status of the work tree that contains GitPath, which is the
+	// directory the status was last requested for. Root is empty if GitPath
+	// isn't in a work tree. The status of the previous directory is kept
+	// until the new one arrives.
+	GitPath string
+	Git     gitstatus.Status
+
+	// Keeps track of which entry the cursor was on last time for a given
+	// directory. This improves the experience of navigation by allowing the
+	// user to quickly go back to the original path after they've changed
+	// directories multiple times. Entries are remembered by name so that the
+	// cursor finds them even if other entries have come or gone since.
+	PositionHistory map[string]string
+
+	// History holds the directories that were changed into, oldest first,
+	// along with the entries that were selected when they were left.
+	// HistoryIdx is the position of the current directory in it. Going back
+	// and forth only moves HistoryIdx, while changing into a directory any
+	// other way drops whatever comes after it. HistoryMenuIdx is the entry
+	// selected in the history popup.
+	History        []history.Entry
+	HistoryIdx     int
+	HistoryMenuIdx int
+
+	// WatchedPaths are the directories shown in the panes, which are watched
+	// for changes.
+	WatchedPaths []string
+
+	// Used when the number of files is higher than what can fit on the screen.
+	// This value indicates how many lines/rows have been scrolled past by the
+	// user.
+	ScrollOffset int
+	SelectedIdx  int
+
+	// Keymap resolves key presses into actions. PendingKeys holds the keys
+	// pressed so far while they're the beginning of a longer binding such as
+	// gg.
+	Keymap      *Keymap
+	PendingKeys []KeyStroke
+
+	Marks map[rune]string
+
+	// MissingMarks holds the marks whose directories couldn't be found the
+	// last time the marks were loaded.
+	MissingMarks map[rune]bool
+
+	// MarkIdx is the row selected in the mark manager. While RenamingMark is
+	// set, the next key press becomes the new key of the selected mark.
+	MarkIdx      int
+	RenamingMark bool
+
+	Finder FinderState
+
+	Width  int
+	Height int
+
+	// Err is shown in the bottom line until the next key press.
+	Err error
+
+	// cursor says where the cursor should land once the listing for Path
+	// arrives.
+	cursor cursorTarget
+
+	// returnTo is where the user was before changing into Path. It's changed
+	// back into if Path can't be read.
+	returnTo departure
+
+	// markToSelect is the mark that the mark manager should select once the
+	// marks are loaded again, e.g. after the selected mark was renamed.
+	markToSelect rune
+}
+
+// cursorTarget describes a pending cursor position. If name is set, the entry
+// with that name is selected. Otherwise, idx is used.
+type cursorTarget struct {
+	name string
+	idx  int
+}
+
+// departure is the directory that was left for another one and the entry that
+// was selected in it, along with the history at that point.
+type departure struct {
+	history.Entry
+	stack    []history.Entry
+	stackIdx int
+}
+
+// NewState returns the state for a navigator that starts in path. The listing
+// for path isn't loaded until the effects returned by Init are carried out.
+func NewState(path string, showHiddenFiles bool, marks map[rune]string, keymap *Keymap) State {
+	if marks == nil {
+		marks = make(map[rune]string)
+	}
+	if keymap == nil {
+		keymap = DefaultKeymap()
+	}
+
+	return State{
+		Path:            path,
+		Mode:            ModeDefault,
+		SearchBarPrefix: SearchBarPrefixNavigating,
+		ShowHiddenFiles: showHiddenFiles,
+		PositionHistory: make(map[string]string),
+		History:         []history.Entry{{Path: path}},
+		Marks:           marks,
+		Keymap:          keymap,
+	}
+}
+
+// Init returns the effects that need to be carried out before s can be drawn
+// for the first time.
+func Init(s State) []Effect {
+	return []Effect{EffectLoadDir{Pane: PaneCurrent, Path: s.Path}}
+}
+
+// SelectedEntry returns the entry under the cursor, if there is one.
+func (s State) SelectedEntry() (fs.DirEntry, bool) {
+	if s.SelectedIdx < 0 || s.SelectedIdx >= len(s.Files) {
+		return nil, false
+	}
+
+	return s.Files[s.SelectedIdx], true
+}
+
+// markKeys returns the keys of every mark in the order they're listed in the
+// mark manager.
+func (s State) markKeys() []rune {
+	return slices.Sorted(maps.Keys(s.Marks))
+}
+
+// SelectedMark returns the key of the mark selected in the mark manager, if
+// there is one.
+func (s State) SelectedMark() (rune, bool) {
+	keys := s.markKeys()
+	if s.MarkIdx < 0 || s.MarkIdx >= len(keys) {
+		return 0, false
+	}
+
+	return keys[s.MarkIdx], true
+}
+
+// sortOrderFor returns the order used for the listing of path.
+func (s State) sortOrderFor(path string) sorting.Order {
+	if order, ok := s.SortOrders[path]; ok {
+		return order
+	}
+
+	return s.DefaultSort
+}
+
+// visualRange returns the indexes in Files of the first and the last entry in
+// the visual range, if there is one.
+func (s State) visualRange() (int, int, bool) {
+	if s.VisualDir == "" || s.VisualDir != s.Path || len(s.Files) == 0 {
+		return 0, 0, false
+	}
+
+	start := slices.IndexFunc(s.Files, func(f fs.DirEntry) bool {
+		return f.Name() == s.VisualStart
+	})
+	if start == -1 {
+		start = s.SelectedIdx
+	}
+
+	return min(start, s.SelectedIdx), max(start, s.SelectedIdx), true
+}
+
+// markedPaths returns the selection along with the entries in the visual
+// range that can be picked.
+func (s State) markedPaths() map[string]bool {
+	result := maps.Clone(s.Selection)
+	if result == nil {
+		result = make(map[string]bool)
+	}
+
+	if first, last, ok := s.visualRange(); ok {
+		for _, f := range s.Files[first : last+1] {
+			if s.Pick.accepts(f.IsDir()) {
+				result[filepath.Join(s.Path, f.Name())] = true
+			}
+		}
+	}
+
+	return result
+}
+
+// searchNarrowed reports whether Files has been narrowed down by a search.
+func (s State) searchNarrowed() bool {
+	return (s.Mode == ModeSearch && s.SearchEntry != "") || len(s.Files) != len(s.Entries)
+}
+
+// withLoading records whether the listing of pane is still being read. The
+// map is copied first since older states might still be holding onto it.
+func (s State) withLoading(pane Pane, loading bool) State {
+	if s.Loading[pane] == loading {
+		return s
+	}
+
+	result := maps.Clone(s.Loading)
+	if result == nil {
+		result = make(map[Pane]bool)
+	}
+
+	if loading {
+		result[pane] = true
+	} else {
+		delete(result, pane)
+	}
+	s.Loading = result
+
+	return s
+}
+
+// withLoadErr records the error the listing of pane failed with, or that it
+// didn't fail if err is nil. The map is copied first since older states might
+// still be holding onto it.
+func (s State) withLoadErr(pane Pane, err error) State {
+	if s.LoadErrs[pane] == err {
+		return s
+	}
+
+	result := maps.Clone(s.LoadErrs)
+	if result == nil {
+		result = make(map[Pane]error)
+	}
+
+	if err != nil {
+		result[pane] = err
+	} else {
+		delete(result, pane)
+	}
+	s.LoadErrs = result
+
+	return s
+}
+
+// knownLoadErr returns the error that the listing of path failed with if it's
+// shown in the parent or child pane.
+func (s State) knownLoadErr(path string) error {
+	switch path {
+	case s.ParentPath:
+		return s.LoadErrs[PaneParent]
+	case s.ChildPath:
+		return s.LoadErrs[PaneChild]
+	}
+
+	return nil
+}
+
+// withSortOrder sorts the current directory with order from now on. The
+// cursor stays on the same entry.
+func (s State) withSortOrder(order sorting.Order) (State, []Effect) {
+	orders := maps.Clone(s.SortOrders)
+	if orders == nil {
+		orders = make(map[string]sorting.Order)
+	}
+
+	if order == s.DefaultSort {
+		delete(orders, s.Path)
+	} else {
+		orders[s.Path] = order
+	}
+	s.SortOrders = orders
+
+	selected, hadSelection := s.SelectedEntry()
+	s.Entries = order.Sort(s.Entries)
+	s.Files = order.Sort(s.Files)
+
+	if hadSelection {
+		s.SelectedIdx = max(slices.IndexFunc(s.Files, func(f fs.DirEntry) bool {
+			return f.Name() == selected.Name()
+		}), 0)
+	}
+
+	return s.withScrollOffset(), []Effect{EffectSaveSortOrder{Path: s.Path, Order: order}}
+}
+
+// listHeight returns how many rows are available for file entries in each
+// pane. The top two rows hold the path indicator and the bottom row holds the
+// info line.
+func (s State) listHeight() int {
+	return max(s.Height-3, 1)
+}
+
+func (s State) withScrollOffset() State {
+	s.ScrollOffset = calculateScrollOffsetForHeight(s.SelectedIdx, s.ScrollOffset, s.listHeight(), len(s.Files))
+	return s
+}
+
+// rememberPosition records the entry under the cursor for the current path.
+// The map is copied first since older states might still be holding onto it.
+func (s State) rememberPosition() State {
+	f, ok := s.SelectedEntry()
+	if !ok || s.PositionHistory[s.Path] == f.Name() {
+		return s
+	}
+
+	history := maps.Clone(s.PositionHistory)
+	if history == nil {
+		history = make(map[string]string)
+	}
+	history[s.Path] = f.Name()
+	s.PositionHistory = history
+
+	return s
+}
+
+// rememberedPosition returns the cursor target for the entry that was under
+// the cursor when path was last left. The cursor lands at the top if there's
+// no such entry.
+func (s State) rememberedPosition(path string) cursorTarget {
+	return cursorTarget{name: s.PositionHistory[path]}
+}
+
+// historySoFar returns a copy of the history with the entry under the cursor
+// recorded for the current directory, along with the position of the current
+// directory in it.
+func (s State) historySoFar() ([]history.Entry, int) {
+	stack := slices.Clone(s.History)
+	idx := s.HistoryIdx
+
+	if idx < 0 || idx >= len(stack) || stack[idx].Path != s.Path {
+		stack = append(stack, history.Entry{Path: s.Path})
+		idx = len(stack) - 1
+	}
+	if f, ok := s.SelectedEntry(); ok {
+		stack[idx].Name = f.Name()
+	}
+
+	return stack, idx
+}
+
+// withStoredHistory puts the history kept from earlier sessions before the
+// current directory.
+func (s State) withStoredHistory(stored []history.Entry) State {
+	stack := slices.Clone(stored)
+	if len(stack) == 0 || stack[len(stack)-1].Path != s.Path {
+		stack = append(stack, history.Entry{Path: s.Path})
+	}
+
+	s.History = stack[max(len(stack)-history.Max, 0):]
+	s.HistoryIdx = len(s.History) - 1
+
+	return s
+}
+
+// changeDirectory switches to path and adds it to the history, dropping
+// whatever had been gone back from. The cursor lands on target once the
+// listing arrives.
+func (s State) changeDirectory(path string, target cursorTarget) (State, []Effect) {
+	from := s.Path
+	stack, idx := s.historySoFar()
+
+	s, effects := s.switchDirectory(path, target)
+	if s.Path == from {
+		// Either the directory is being reloaded or it couldn't be changed
+		// into.
+		return s, effects
+	}
+
+	stack = append(stack[:idx+1], history.Entry{Path: path})
+	s.History = stack[max(len(stack)-history.Max, 0):]
+	s.HistoryIdx = len(s.History) - 1
+
+	return s, effects
+}
+
+// moveInHistory switches to the directory at idx in the history. The cursor
+// lands on the entry that was selected when it was left.
+func (s State) moveInHistory(idx int) (State, []Effect) {
+	stack, _ := s.historySoFar()
+	if idx < 0 || idx >= len(stack) {
+		return s, nil
+	}
+
+	entry := stack[idx]
+	s = s.rememberPosition()
+
+	s, effects := s.switchDirectory(entry.Path, cursorTarget{name: entry.Name})
+	if s.Path == entry.Path {
+		s.History = stack
+		s.HistoryIdx = idx
+	}
+
+	return s, effects
+}
+
+// switchDirectory switches to path and requests its listing. The cursor lands
+// on target once the listing arrives. Directories that are already known to be
+// unreadable aren't changed into.
+func (s State) switchDirectory(path string, target cursorTarget) (State, []Effect) {
+	if path != "" && path != s.Path {
+		if err := s.knownLoadErr(path); err != nil {
+			s.Err = err
+			return s, nil
+		}
+
+		s.returnTo = departure{Entry: history.Entry{Path: s.Path}, stack: s.History, stackIdx: s.HistoryIdx}
+		if f, ok := s.SelectedEntry(); ok {
+			s.returnTo.Name = f.Name()
+		}
+	}
+
+	s.Path = path
+	s.Entries = nil
+	s.Files = nil
+	s.SelectedIdx = 0
+	s.ScrollOffset = 0
+	s.cursor = target
+	s = s.withLoading(PaneCurrent, false).withLoadErr(PaneCurrent, nil)
+
+	return s, []Effect{EffectLoadDir{Pane: PaneCurrent, Path: path}}
+}
+
+// reload requests the listing for the current path again while trying to keep
+// the cursor on the same entry, or on the same row if the entry is gone.
+func (s State) reload() (State, []Effect) {
+	target := cursorTarget{idx: s.SelectedIdx}
+	if f, ok := s.SelectedEntry(); ok {
+		target.name = f.Name()
+	}
+
+	return s.changeDirectory(s.Path, target)
+}
+
+// refresh requests the listing for the current path again without clearing
+// what's shown. The cursor stays on the same entry, or on the same row if the
+// entry is gone by then.
+func (s State) refresh() (State, []Effect) {
+	s.cursor = cursorTarget{idx: s.SelectedIdx}
+	if f, ok := s.SelectedEntry(); ok {
+		s.cursor.name = f.Name()
+	}
+
+	return s, []Effect{EffectLoadDir{Pane: PaneCurrent, Path: s.Path}}
+}
+
+// holdCursor makes the entry under the cursor the cursor target unless there
+// already is one, so that the cursor stays on it while the listing is being
+// replaced.
+func (s State) holdCursor() State {
+	if s.cursor != (cursorTarget{}) {
+		return s
+	}
+
+	if f, ok := s.SelectedEntry(); ok {
+		s.cursor = cursorTarget{name: f.Name(), idx: s.SelectedIdx}
+	}
+
+	return s
+}
+
+// followCursorTarget is applyCursorTarget for listings that are still being
+// read. If the target hasn't been read yet, it's kept for the next part.
+func (s State) followCursorTarget() State {
+	idx := slices.IndexFunc(s.Files, func(f fs.DirEntry) bool {
+		return s.cursor.name != "" && f.Name() == s.cursor.name
+	})
+	if idx != -1 {
+		s.SelectedIdx = idx
+		s.cursor = cursorTarget{}
+	} else {
+		s.SelectedIdx = min(max(s.cursor.idx, 0), max(len(s.Files)-1, 0))
+	}
+
+	return s.withScrollOffset()
+}
+
+// applyCursorTarget moves the cursor to the entry named by the pending target
+// or, if there's no such entry, to its row.
+func (s State) applyCursorTarget() State {
+	s.SelectedIdx = 0
+
+	idx := slices.IndexFunc(s.Files, func(f fs.DirEntry) bool {
+		return s.cursor.name != "" && f.Name() == s.cursor.name
+	})
+	if idx != -1 {
+		s.SelectedIdx = idx
+	} else if len(s.Files) > 0 {
+		s.SelectedIdx = min(max(s.cursor.idx, 0), len(s.Files)-1)
+	}
+
+	s.cursor = cursorTarget{}
+
+	return s.withScrollOffset()
+}
+
+// syncPanes requests listings for the parent and child panes, a preview of and
+// details about the selected entry and the git status of the current
+// directory if they don't match the current path and selection anymore. It
+// also asks for the directories in the panes to be watched.
+func syncPanes(s State) (State, []Effect) {
+	effects := []Effect{}
+
+	parentPath := filepath.Dir(s.Path)
+	if parentPath == s.Path {
+		// We're at the root and there's nothing above it.
+		parentPath = ""
+	}
+
+	if parentPath != s.ParentPath {
+		s.ParentPath = parentPath
+		s.ParentFiles = nil
+		s = s.withLoading(PaneParent, false).withLoadErr(PaneParent, nil)
+
+		if parentPath != "" {
+			effects = append(effects, EffectLoadDir{Pane: PaneParent, Path: parentPath})
+		} else {
+			effects = append(effects, EffectStopLoadingDir{Pane: PaneParent})
+		}
+	}
+
+	childPath, previewPath := "", ""
+	if f, ok := s.SelectedEntry(); ok && f.IsDir() {
+		childPath = filepath.Join(s.Path, f.Name())
+	} else if ok {
+		previewPath = filepath.Join(s.Path, f.Name())
+	}
+
+	if childPath != s.ChildPath {
+		s.ChildPath = childPath
+		s.ChildFiles = nil
+		s = s.withLoading(PaneChild, false).withLoadErr(PaneChild, nil)
+
+		if childPath != "" {
+			effects = append(effects, EffectLoadDir{Pane: PaneChild, Path: childPath})
+		} else {
+			effects = append(effects, EffectStopLoadingDir{Pane: PaneChild})
+		}
+	}
+
+	infoPath := ""
+	if f, ok := s.SelectedEntry(); ok && s.ShowInfo {
+		infoPath = filepath.Join(s.Path, f.Name())
+	}
+
+	if infoPath != s.InfoPath {
+		if s.InfoPath != "" {
+			effects = append(effects, EffectStopDiskUsage{})
+		}
+
+		s.InfoPath = infoPath
+		s.Info = fileinfo.Info{}
+		s.InfoErr = nil
+		s.Usage = fileinfo.Usage{}
+		s.UsageDone = false
+
+		if infoPath != "" {
+			effects = append(effects, EffectLoadInfo{Path: infoPath})
+		}
+	}
+
+	if s.Path != s.GitPath {
+		s.GitPath = s.Path
+		effects = append(effects, EffectLoadGitStatus{Path: s.Path})
+	}
+
+	watched := []string{}
+	for _, path := range []string{s.Path, s.ParentPath, s.ChildPath} {
+		if path != "" {
+			watched = append(watched, path)
+		}
+	}
+	if !slices.Equal(watched, s.WatchedPaths) {
+		s.WatchedPaths = watched
+		effects = append(effects, EffectWatchDirs{Paths: watched})
+	}
+
+	if previewPath != s.PreviewPath {
+		s.PreviewPath = previewPath
+		s.Preview = preview.Preview{}
+		s.PreviewTokens = nil
+		s.PreviewErr = nil
+
+		if previewPath != "" {
+			effects = append(effects, EffectLoadPreview{Path: previewPath})
+		}
+	}
+
+	return s, effects
+}
+
+// filterEntries drops hidden files unless they should be shown as well as the
+// files whose names are in ignored, and sorts the result. A new slice is always
+// returned.
+func filterEntries(
+	rawFiles []fs.DirEntry,
+	showHiddenFiles bool,
+	ignored map[string]bool,
+	order sorting.Order,
+) []fs.DirEntry {
+	result := make([]fs.DirEntry, 0, len(rawFiles))
+
+	for _, f := range rawFiles {
+		if !showHiddenFiles && strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		if ignored[f.Name()] {
+			continue
+		}
+
+		result = append(result, f)
+	}
+
+	return order.Sort(result)
+}
+
+// BUG: Wrapping is buggy right now. Try wrapping in a directory with a lot of files.
+func calculateScrollOffsetForHeight(selectedIdx, currScrollOffset, heightUsableForFiles, listLen int) int {
+	result := 0
+
+	if selectedIdx < currScrollOffset {
+		// Gone over the top edge. The scroll marker should be where the current
+		// file marker is.
+		result = selectedIdx
+	} else if selectedIdx >= currScrollOffset+heightUsableForFiles {
+		// Gone over the bottom edge. Since the file marker is at the bottom,
+		// the scroll marker should be (heightUsableForFileList - 1) rows behind
+		// the file marker.
+		result = selectedIdx - (heightUsableForFiles - 1)
+	} else {
+		// Keep the scroll offset the same as long as the edges are not being touched.
+		result = currScrollOffset
+	}
+
+	maxOffset := max((listLen-1)-(heightUsableForFiles-1), 0)
+
+	// Nothing is selected if selectedIdx is -1.
+	return max(min(result, maxOffset), 0)
+}
@@ -0,0 +1,117 @@
+package tui
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/bnuredini/pathsurfer/internal/highlight"
+)
+
+var defaultSyntaxStyles = map[highlight.Kind]tcell.Style{
+	highlight.KindKeyword:  tcell.StyleDefault.Foreground(tcell.ColorPurple).Bold(true),
+	highlight.KindType:     tcell.StyleDefault.Foreground(tcell.ColorTeal),
+	highlight.KindString:   tcell.StyleDefault.Foreground(tcell.ColorGreen),
+	highlight.KindNumber:   tcell.StyleDefault.Foreground(tcell.ColorOlive),
+	highlight.KindComment:  tcell.StyleDefault.Foreground(tcell.ColorGray).Italic(true),
+	highlight.KindKey:      tcell.StyleDefault.Foreground(tcell.ColorBlue),
+	highlight.KindVariable: tcell.StyleDefault.Foreground(tcell.ColorTeal),
+	highlight.KindHeading:  tcell.StyleDefault.Foreground(tcell.ColorBlue).Bold(true),
+}
+
+// syntaxKindsByName holds the names under which the kinds of tokens are styled
+// in the config file.
+var syntaxKindsByName = map[string]highlight.Kind{
+	"keyword":  highlight.KindKeyword,
+	"type":     highlight.KindType,
+	"string":   highlight.KindString,
+	"number":   highlight.KindNumber,
+	"comment":  highlight.KindComment,
+	"key":      highlight.KindKey,
+	"variable": highlight.KindVariable,
+	"heading":  highlight.KindHeading,
+}
+
+// DefaultSyntaxStyles returns the styles used for highlighted source code when
+// nothing is configured.
+func DefaultSyntaxStyles() map[highlight.Kind]tcell.Style {
+	return maps.Clone(defaultSyntaxStyles)
+}
+
+// NewSyntaxStyles returns the default syntax styles with the given ones applied
+// on top of them. The map is keyed by kind name ("keyword", "type", "string",
+// "number", "comment", "key", "variable" or "heading") and the values are
+// parsed with ParseStyle. An empty style draws the kind like plain text.
+func NewSyntaxStyles(overrides map[string]string) (map[highlight.Kind]tcell.Style, error) {
+	result := DefaultSyntaxStyles()
+
+	for name, spec := range overrides {
+		kind, ok := syntaxKindsByName[name]
+		if !ok {
+			return nil, fmt.Errorf("syntax: unknown kind %q", name)
+		}
+
+		style, err := ParseStyle(spec)
+		if err != nil {
+			return nil, fmt.Errorf("syntax: %s: %w", name, err)
+		}
+		result[kind] = style
+	}
+
+	return result, nil
+}
+
+// ParseStyle parses a style written as space-separated words, such as
+// "purple bold" or "#ffaf00 on black italic". A color on its own sets the
+// foreground and one after "on" the background. Colors are either names
+// known to tcell or written as #rrggbb. The attributes are "bold", "italic",
+// "underline", "dim" and "reverse".
+func ParseStyle(spec string) (tcell.Style, error) {
+	style := tcell.StyleDefault
+
+	words := strings.Fields(strings.ToLower(spec))
+	for i := 0; i < len(words); i++ {
+		switch word := words[i]; word {
+		case "bold":
+			style = style.Bold(true)
+		case "italic":
+			style = style.Italic(true)
+		case "underline":
+			style = style.Underline(true)
+		case "dim":
+			style = style.Dim(true)
+		case "reverse":
+			style = style.Reverse(true)
+		case "on":
+			if i+1 == len(words) {
+				return style, fmt.Errorf("missing color after %q", word)
+			}
+			i++
+
+			color, err := parseColor(words[i])
+			if err != nil {
+				return style, err
+			}
+			style = style.Background(color)
+		default:
+			color, err := parseColor(word)
+			if err != nil {
+				return style, err
+			}
+			style = style.Foreground(color)
+		}
+	}
+
+	return style, nil
+}
+
+func parseColor(name string) (tcell.Color, error) {
+	color := tcell.GetColor(name)
+	if color == tcell.ColorDefault && name != "default" {
+		return color, fmt.Errorf("unknown color %q", name)
+	}
+
+	return color, nil
+}
@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/bnuredini/pathsurfer/internal/highlight"
+)
+
+func TestParseStyle(t *testing.T) {
+	data := []struct {
+		Spec string
+		Want tcell.Style
+	}{
+		{"", tcell.StyleDefault},
+		{"red", tcell.StyleDefault.Foreground(tcell.ColorRed)},
+		{"#ffaf00 bold", tcell.StyleDefault.Foreground(tcell.NewHexColor(0xffaf00)).Bold(true)},
+		{"Gray on black italic", tcell.StyleDefault.Foreground(tcell.ColorGray).Background(tcell.ColorBlack).Italic(true)},
+		{"default underline", tcell.StyleDefault.Foreground(tcell.ColorDefault).Underline(true)},
+	}
+
+	for _, tt := range data {
+		got, err := ParseStyle(tt.Spec)
+		if err != nil {
+			t.Errorf("spec=%q: %v", tt.Spec, err)
+			continue
+		}
+		if got != tt.Want {
+			t.Errorf("spec=%q: want=%v, got=%v", tt.Spec, tt.Want, got)
+		}
+	}
+
+	for _, spec := range []string{"reddish", "red on", "#12345"} {
+		if _, err := ParseStyle(spec); err == nil {
+			t.Errorf("spec=%q: want an error", spec)
+		}
+	}
+}
+
+func TestNewSyntaxStyles(t *testing.T) {
+	styles, err := NewSyntaxStyles(map[string]string{
+		"keyword": "red",
+		"comment": "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := styles[highlight.KindKeyword], tcell.StyleDefault.Foreground(tcell.ColorRed); got != want {
+		t.Errorf("keyword: want=%v, got=%v", want, got)
+	}
+	if got, want := styles[highlight.KindComment], tcell.StyleDefault; got != want {
+		t.Errorf("comment: want=%v, got=%v", want, got)
+	}
+	if got, want := styles[highlight.KindString], defaultSyntaxStyles[highlight.KindString]; got != want {
+		t.Errorf("string: want the default style %v, got=%v", want, got)
+	}
+
+	for _, overrides := range []map[string]string{{"operator": "red"}, {"keyword": "reddish"}} {
+		if _, err := NewSyntaxStyles(overrides); err == nil {
+			t.Errorf("overrides=%v: want an error", overrides)
+		}
+	}
+}
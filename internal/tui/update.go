@@ -0,0 +1,685 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/bnuredini/pathsurfer/internal/fileinfo"
+	"github.com/bnuredini/pathsurfer/internal/fuzzy"
+	"github.com/bnuredini/pathsurfer/internal/gitstatus"
+	"github.com/bnuredini/pathsurfer/internal/highlight"
+	"github.com/bnuredini/pathsurfer/internal/preview"
+	"github.com/bnuredini/pathsurfer/internal/sorting"
+	"github.com/bnuredini/pathsurfer/internal/walk"
+)
+
+// Event is something that happened and that the state needs to react to. Key
+// presses and resizes come from the terminal, everything else is the outcome
+// of carrying out an Effect.
+type Event interface {
+	isEvent()
+}
+
+type EventKey struct {
+	Key  tcell.Key
+	Rune rune
+	Mod  tcell.ModMask
+}
+
+type EventResize struct {
+	Width  int
+	Height int
+}
+
+// EventDirLoaded carries the raw, unfiltered listing of a directory. Ignored
+// holds the names of the entries that are matched by ignore files. Partial is
+// set while the directory is still being read, in which case Entries holds
+// what's been read so far.
+type EventDirLoaded struct {
+	Pane    Pane
+	Path    string
+	Entries []fs.DirEntry
+	Ignored map[string]bool
+	Partial bool
+	Err     error
+}
+
+// EventMarksLoaded carries every known mark. Missing holds the marks whose
+// directories don't exist anymore.
+type EventMarksLoaded struct {
+	Marks   map[rune]string
+	Missing map[rune]bool
+	Err     error
+}
+
+// EventFinderResults carries paths found by the walk that was started with an
+// EffectStartFinder with the same ID. Done is set on the last one.
+type EventFinderResults struct {
+	ID      int
+	Entries []walk.Entry
+	Done    bool
+	Err     error
+}
+
+// EventPreviewLoaded carries the beginning of the file at Path.
+type EventPreviewLoaded struct {
+	Path    string
+	Preview preview.Preview
+	Err     error
+}
+
+// EventGitStatusLoaded carries the status of the work tree that contains Path.
+// Status is empty if Path isn't in one.
+type EventGitStatusLoaded struct {
+	Path   string
+	Status gitstatus.Status
+}
+
+// EventInfoLoaded carries details about the entry at Path.
+type EventInfoLoaded struct {
+	Path string
+	Info fileinfo.Info
+	Err  error
+}
+
+// EventDiskUsage carries what's been counted below the directory at Path so
+// far. Done is set once everything has been counted.
+type EventDiskUsage struct {
+	Path  string
+	Usage fileinfo.Usage
+	Done  bool
+}
+
+// EventDirsChanged carries directories whose contents have changed since they
+// were last listed.
+type EventDirsChanged struct {
+	Paths []string
+}
+
+func (EventKey) isEvent()             {}
+func (EventResize) isEvent()          {}
+func (EventDirLoaded) isEvent()       {}
+func (EventMarksLoaded) isEvent()     {}
+func (EventFinderResults) isEvent()   {}
+func (EventPreviewLoaded) isEvent()   {}
+func (EventGitStatusLoaded) isEvent() {}
+func (EventInfoLoaded) isEvent()      {}
+func (EventDiskUsage) isEvent()       {}
+func (EventDirsChanged) isEvent()     {}
+
+// Effect is a request for a side effect. Update never touches the file system
+// itself; it returns effects and whoever is driving it carries them out and
+// feeds the outcome back in as events.
+type Effect interface {
+	isEffect()
+}
+
+// EffectLoadDir asks for the listing of Path. The outcome is reported with
+// EventDirLoaded events for the same pane and path, bit by bit if it takes a
+// while. A listing that's still being read for the same pane is cancelled.
+type EffectLoadDir struct {
+	Pane Pane
+	Path string
+}
+
+// EffectStopLoadingDir asks for the directory that's being read for Pane, if
+// there is one, to be left alone.
+type EffectStopLoadingDir struct {
+	Pane Pane
+}
+
+// EffectLoadPreview asks for the beginning of the file at Path. It's read in
+// the background and reported with an EventPreviewLoaded for the same path,
+// unless another preview is asked for first.
+type EffectLoadPreview struct {
+	Path string
+}
+
+// EffectLoadGitStatus asks for the status of the work tree that contains Path.
+// The outcome is reported with an EventGitStatusLoaded for the same path.
+type EffectLoadGitStatus struct {
+	Path string
+}
+
+// EffectQuitWithSelection asks for the program to quit and print Paths.
+type EffectQuitWithSelection struct {
+	Paths []string
+}
+
+// EffectLoadInfo asks for details about the entry at Path. They're read in the
+// background and reported with an EventInfoLoaded. If the entry is a
+// directory, what's below it is counted next and reported with EventDiskUsage
+// events.
+type EffectLoadInfo struct {
+	Path string
+}
+
+// EffectStopDiskUsage asks for whatever the last EffectLoadInfo started to be
+// stopped.
+type EffectStopDiskUsage struct{}
+
+// EffectStoreMark asks for a mark to be stored. The outcome is reported with
+// an EventMarksLoaded containing every known mark.
+type EffectStoreMark struct {
+	Key  rune
+	Path string
+}
+
+// EffectLoadMarks asks for the marks to be read again. The outcome is reported
+// with an EventMarksLoaded.
+type EffectLoadMarks struct{}
+
+// EffectDeleteMark asks for a mark to be removed. The outcome is reported with
+// an EventMarksLoaded containing every remaining mark.
+type EffectDeleteMark struct {
+	Key rune
+}
+
+// EffectRenameMark asks for the mark From to be moved to the key To. The
+// outcome is reported with an EventMarksLoaded containing every known mark.
+type EffectRenameMark struct {
+	From rune
+	To   rune
+}
+
+// EffectStartFinder asks for everything below Root to be listed in the
+// background. What's found is reported bit by bit with EventFinderResults.
+// A walk that's still running is cancelled first.
+type EffectStartFinder struct {
+	ID            int
+	Root          string
+	ShowHidden    bool
+	RespectIgnore bool
+}
+
+// EffectStopFinder asks for the walk that's running, if there is one, to be
+// cancelled.
+type EffectStopFinder struct{}
+
+// EffectReloadIgnoreFiles asks for ignore files to be read again the next time
+// they're needed.
+type EffectReloadIgnoreFiles struct{}
+
+// EffectSaveSortOrder asks for the order picked for the directory at Path to
+// be remembered. It's saved in the background.
+type EffectSaveSortOrder struct {
+	Path  string
+	Order sorting.Order
+}
+
+// EffectWatchDirs asks for the directories in Paths to be watched instead of
+// the ones watched so far. Changes are reported with EventDirsChanged.
+type EffectWatchDirs struct {
+	Paths []string
+}
+
+// EffectQuit asks for the program to stop. Path is what gets printed for the
+// shell wrappers. It's empty if nothing should be printed.
+type EffectQuit struct {
+	Path string
+}
+
+// EffectCancel asks for the program to stop without printing anything because
+// the user didn't pick anything.
+type EffectCancel struct{}
+
+func (EffectLoadDir) isEffect()           {}
+func (EffectStopLoadingDir) isEffect()    {}
+func (EffectLoadPreview) isEffect()       {}
+func (EffectLoadGitStatus) isEffect()     {}
+func (EffectLoadInfo) isEffect()          {}
+func (EffectQuitWithSelection) isEffect() {}
+func (EffectStopDiskUsage) isEffect()     {}
+func (EffectStoreMark) isEffect()         {}
+func (EffectLoadMarks) isEffect()         {}
+func (EffectDeleteMark) isEffect()        {}
+func (EffectRenameMark) isEffect()        {}
+func (EffectStartFinder) isEffect()       {}
+func (EffectStopFinder) isEffect()        {}
+func (EffectReloadIgnoreFiles) isEffect() {}
+func (EffectSaveSortOrder) isEffect()     {}
+func (EffectWatchDirs) isEffect()         {}
+func (EffectQuit) isEffect()              {}
+func (EffectCancel) isEffect()            {}
+
+// NewEventKey converts a key event coming from tcell.
+func NewEventKey(ev *tcell.EventKey) EventKey {
+	return EventKey{Key: ev.Key(), Rune: ev.Rune(), Mod: ev.Modifiers()}
+}
+
+// Update applies ev to s and returns the new state along with the effects that
+// need to be carried out. It doesn't perform any I/O.
+func Update(s State, ev Event) (State, []Effect) {
+	var effects []Effect
+
+	switch ev := ev.(type) {
+	case EventKey:
+		before := s
+		s, effects = handleKeyPress(s, ev)
+
+		// Moving the cursor while the listing is still being read takes
+		// over from wherever the cursor was going to land.
+		if s.Path == before.Path && s.cursor == before.cursor && s.SelectedIdx != before.SelectedIdx {
+			s.cursor = cursorTarget{}
+		}
+
+	case EventResize:
+		s.Width = ev.Width
+		s.Height = ev.Height
+		s = s.withScrollOffset()
+
+	case EventDirLoaded:
+		s, effects = handleDirLoaded(s, ev)
+
+	case EventDirsChanged:
+		s, effects = handleDirsChanged(s, ev)
+
+	case EventMarksLoaded:
+		s = handleMarksLoaded(s, ev)
+
+	case EventFinderResults:
+		s = handleFinderResults(s, ev)
+
+	case EventGitStatusLoaded:
+		if ev.Path == s.GitPath {
+			s.Git = ev.Status
+		}
+
+	case EventInfoLoaded:
+		if ev.Path == s.InfoPath {
+			s.Info = ev.Info
+			s.InfoErr = ev.Err
+		}
+
+	case EventDiskUsage:
+		if ev.Path == s.InfoPath {
+			s.Usage = ev.Usage
+			s.UsageDone = ev.Done
+		}
+
+	case EventPreviewLoaded:
+		if ev.Path == s.PreviewPath {
+			s.Preview = ev.Preview
+			s.PreviewErr = ev.Err
+
+			if ev.Preview.Kind == preview.KindText {
+				s.PreviewTokens = highlight.Highlight(ev.Path, ev.Preview.Lines)
+			}
+		}
+	}
+
+	s, paneEffects := syncPanes(s)
+
+	return s, append(effects, paneEffects...)
+}
+
+func handleDirLoaded(s State, ev EventDirLoaded) (State, []Effect) {
+	var ignored map[string]bool
+	if s.RespectIgnore {
+		ignored = ev.Ignored
+	}
+
+	switch ev.Pane {
+	case PaneCurrent:
+		if ev.Path != s.Path {
+			// The user has already moved on to another directory.
+			return s, nil
+		}
+
+		s = s.withLoading(PaneCurrent, ev.Partial)
+		if ev.Err != nil && len(ev.Entries) == 0 && s.returnTo.Path != "" {
+			// Rather than leaving the user in a directory they can't see
+			// into, go back to where they came from.
+			returnTo := s.returnTo
+			s, effects := s.switchDirectory(returnTo.Path, cursorTarget{name: returnTo.Name})
+			s.returnTo = departure{}
+			s.History = returnTo.stack
+			s.HistoryIdx = returnTo.stackIdx
+			s.Err = ev.Err
+
+			return s, effects
+		}
+
+		if !ev.Partial {
+			s.returnTo = departure{}
+		}
+		s = s.withLoadErr(PaneCurrent, ev.Err)
+		if ev.Err != nil {
+			s.Err = ev.Err
+		}
+
+		narrowed := s.searchNarrowed()
+		s = s.holdCursor()
+		s.Entries = filterEntries(ev.Entries, s.ShowHiddenFiles, ignored, s.sortOrderFor(ev.Path))
+
+		switch {
+		case s.Mode == ModeSearch && s.SearchEntry != "":
+			s.Files = s.sortOrderFor(s.Path).Sort(searchInDir(s.SearchEntry, s.Entries))
+		case !narrowed:
+			s.Files = s.Entries
+		}
+
+		if ev.Partial {
+			return s.followCursorTarget(), nil
+		}
+
+		return s.applyCursorTarget(), nil
+
+	case PaneParent:
+		if ev.Path == s.ParentPath {
+			s.ParentFiles = filterEntries(ev.Entries, s.ShowHiddenFiles, ignored, s.sortOrderFor(ev.Path))
+			s = s.withLoading(PaneParent, ev.Partial).withLoadErr(PaneParent, ev.Err)
+		}
+
+	case PaneChild:
+		if ev.Path == s.ChildPath {
+			s.ChildFiles = filterEntries(ev.Entries, s.ShowHiddenFiles, ignored, s.sortOrderFor(ev.Path))
+			s = s.withLoading(PaneChild, ev.Partial).withLoadErr(PaneChild, ev.Err)
+		}
+	}
+
+	return s, nil
+}
+
+// handleDirsChanged requests the listings of the panes showing the changed
+// directories again. A listing narrowed down by a search isn't refreshed so
+// that the results don't change while they're being looked at.
+func handleDirsChanged(s State, ev EventDirsChanged) (State, []Effect) {
+	effects := []Effect{}
+
+	for _, path := range ev.Paths {
+		switch path {
+		case s.Path:
+			if s.searchNarrowed() {
+				continue
+			}
+
+			var refreshEffects []Effect
+			s, refreshEffects = s.refresh()
+			effects = append(effects, refreshEffects...)
+
+			// The status of the work tree is likely to have changed as well.
+			s.GitPath = ""
+
+		case s.ParentPath:
+			effects = append(effects, EffectLoadDir{Pane: PaneParent, Path: path})
+
+		case s.ChildPath:
+			effects = append(effects, EffectLoadDir{Pane: PaneChild, Path: path})
+		}
+
+		if s.PreviewPath != "" && filepath.Dir(s.PreviewPath) == path {
+			// Asks syncPanes to load the preview again.
+			s.PreviewPath = ""
+		}
+	}
+
+	return s, effects
+}
+
+func handleMarksLoaded(s State, ev EventMarksLoaded) State {
+	target := s.markToSelect
+	s.markToSelect = 0
+
+	if ev.Err != nil {
+		s.Err = ev.Err
+		return s
+	}
+
+	s.Marks = ev.Marks
+	s.MissingMarks = ev.Missing
+
+	keys := s.markKeys()
+	if idx := slices.Index(keys, target); target != 0 && idx != -1 {
+		s.MarkIdx = idx
+	}
+	s.MarkIdx = min(max(s.MarkIdx, 0), max(len(keys)-1, 0))
+
+	return s
+}
+
+func handleKeyPress(s State, ev EventKey) (State, []Effect) {
+	s.Err = nil
+
+	// Some terminals deliver Ctrl+C as \x03. Code point 3 is the ASCII ETX
+	// control character.
+	if ev.Key == tcell.KeyCtrlC || ev.Rune == 3 {
+		return quit(s)
+	}
+
+	switch s.Mode {
+	case ModeDefault:
+		return handleKeyPressInDefault(s, ev)
+
+	case ModeSearch:
+		return handleKeyPressInSearch(s, ev)
+
+	case ModeRecordingMark:
+		if ev.Key == tcell.KeyESC {
+			s.Mode = ModeDefault
+			return s, nil
+		}
+
+		if ev.Key != tcell.KeyRune {
+			s.Err = errors.New("setting mark: value for mark must be a rune")
+			return s, nil
+		}
+
+		s.Mode = ModeDefault
+		return s, []Effect{EffectStoreMark{Key: ev.Rune, Path: s.Path}}
+
+	case ModeListeningForMark:
+		return handleKeyPressInListeningForMark(s, ev)
+
+	case ModeMarkManager:
+		if s.RenamingMark {
+			return handleKeyPressWhileRenamingMark(s, ev)
+		}
+
+		s, actions := resolveKeys(s, ev)
+		return runActions(s, actions)
+
+	case ModeFinder:
+		return handleKeyPressInFinder(s, ev)
+
+	case ModeHistory:
+		s, actions := resolveKeys(s, ev)
+		return runActions(s, actions)
+	}
+
+	return s, nil
+}
+
+func handleKeyPressInListeningForMark(s State, ev EventKey) (State, []Effect) {
+	s.Mode = ModeDefault
+
+	if ev.Key == tcell.KeyESC {
+		return s, nil
+	}
+
+	if ev.Key != tcell.KeyRune {
+		s.Err = errors.New("jumping to mark: value for mark must be a rune")
+		return s, nil
+	}
+
+	return jumpToMark(s, ev.Rune)
+}
+
+// jumpToMark changes into the directory of the given mark.
+func jumpToMark(s State, key rune) (State, []Effect) {
+	path, ok := s.Marks[key]
+	if !ok {
+		s.Err = fmt.Errorf("jumping to mark: mark %q isn't set", key)
+		return s, nil
+	}
+
+	if s.MissingMarks[key] {
+		s.Err = fmt.Errorf("jumping to mark: %s doesn't exist anymore", path)
+		return s, nil
+	}
+
+	if path == s.Path {
+		return s, nil
+	}
+
+	s = s.rememberPosition()
+	return s.changeDirectory(path, s.rememberedPosition(path))
+}
+
+func handleKeyPressWhileRenamingMark(s State, ev EventKey) (State, []Effect) {
+	s.RenamingMark = false
+
+	if ev.Key == tcell.KeyESC {
+		return s, nil
+	}
+
+	if ev.Key != tcell.KeyRune {
+		s.Err = errors.New("renaming mark: value for mark must be a rune")
+		return s, nil
+	}
+
+	key, ok := s.SelectedMark()
+	if !ok {
+		return s, nil
+	}
+
+	s.markToSelect = ev.Rune
+	return s, []Effect{EffectRenameMark{From: key, To: ev.Rune}}
+}
+
+func handleKeyPressInDefault(s State, ev EventKey) (State, []Effect) {
+	s, actions := resolveKeys(s, ev)
+	return runActions(s, actions)
+}
+
+func handleKeyPressInSearch(s State, ev EventKey) (State, []Effect) {
+	s, actions, typed := resolveTypedKeys(s, ev)
+	if typed != "" {
+		s = typeInSearch(s, typed)
+	}
+	if len(actions) > 0 || len(s.PendingKeys) > 0 {
+		return runActions(s, actions)
+	}
+
+	if ev.Key == tcell.KeyRune {
+		s = typeInSearch(s, string(ev.Rune))
+	}
+
+	return s, nil
+}
+
+// typeInSearch adds text to the search query and narrows down the listing.
+func typeInSearch(s State, text string) State {
+	s.SearchEntry = s.SearchEntry + text
+	s.Files = s.sortOrderFor(s.Path).Sort(searchInDir(s.SearchEntry, s.Files))
+
+	// The marker is at the top of the list while searching.
+	s.SelectedIdx = 0
+	s.ScrollOffset = 0
+
+	return s
+}
+
+// resolveKeys adds ev to the keys pressed so far and returns the actions that
+// the keymap resolves them to. While the keys pressed so far are the beginning
+// of a longer binding, nothing is returned and the keys are kept in
+// s.PendingKeys.
+//
+// If the pending keys are bound to an action themselves but the next key
+// doesn't continue the longer binding, that action is returned and the next
+// key is resolved on its own.
+func resolveKeys(s State, ev EventKey) (State, []Action) {
+	s, actions, _ := resolveTypedKeys(s, ev)
+	return s, actions
+}
+
+// resolveTypedKeys is like resolveKeys, but it also returns the characters
+// among the pending keys when they turn out not to be bound to anything. Modes
+// with a text field insert them rather than dropping them, so that a binding
+// such as "jk" doesn't eat the "j" of a query.
+func resolveTypedKeys(s State, ev EventKey) (State, []Action, string) {
+	keymap := s.Keymap
+	if keymap == nil {
+		keymap = DefaultKeymap()
+	}
+
+	seq := append(slices.Clone(s.PendingKeys), NewKeyStroke(ev))
+	action, found, isPrefix := keymap.lookup(s.Mode, seq)
+
+	if isPrefix {
+		s.PendingKeys = seq
+		return s, nil, ""
+	}
+
+	if found {
+		s.PendingKeys = nil
+		return s, []Action{action}, ""
+	}
+
+	if len(s.PendingKeys) == 0 {
+		return s, nil, ""
+	}
+
+	actions := []Action{}
+	typed := ""
+	if pendingAction, found, _ := keymap.lookup(s.Mode, s.PendingKeys); found {
+		actions = append(actions, pendingAction)
+	} else {
+		for _, key := range s.PendingKeys {
+			if key.Key == tcell.KeyRune && !key.Alt {
+				typed += string(key.Rune)
+			}
+		}
+	}
+
+	s.PendingKeys = nil
+	s, rest := resolveKeys(s, ev)
+
+	return s, append(actions, rest...), typed
+}
+
+func runActions(s State, actions []Action) (State, []Effect) {
+	effects := []Effect{}
+
+	for _, action := range actions {
+		spec, ok := Actions[action]
+		if !ok || spec.Mode != s.Mode {
+			continue
+		}
+
+		var actionEffects []Effect
+		s, actionEffects = spec.run(s)
+		effects = append(effects, actionEffects...)
+	}
+
+	return s, effects
+}
+
+func searchInDir(pattern string, candidateFiles []fs.DirEntry) []fs.DirEntry {
+	if len(candidateFiles) == 0 {
+		return []fs.DirEntry{}
+	}
+	// PERF: Use better/custom data structures to avoid these transformations.
+	result := []fs.DirEntry{}
+	candidates := []string{}
+	candidatesMap := make(map[string]fs.DirEntry)
+
+	for _, f := range candidateFiles {
+		candidates = append(candidates, f.Name())
+		candidatesMap[f.Name()] = f
+	}
+
+	matches := fuzzy.Find(pattern, candidates)
+	for _, match := range matches {
+		if dirEntry, ok := candidatesMap[match.CandidateString]; ok {
+			result = append(result, dirEntry)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,296 @@
+package tui
+
+import (
+	"io/fs"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/bnuredini/pathsurfer/internal/walk"
+)
+
+func testEntries(t *testing.T, names ...string) []fs.DirEntry {
+	t.Helper()
+
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys[name] = &fstest.MapFile{}
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entries
+}
+
+func runeKey(r rune) EventKey {
+	return EventKey{Key: tcell.KeyRune, Rune: r}
+}
+
+func TestUpdateNavigation(t *testing.T) {
+	s := NewState("/tmp", false, nil, nil)
+	s, _ = Update(s, EventResize{Width: 80, Height: 24})
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp", Entries: testEntries(t, "b", "a", ".hidden", "c")})
+
+	if got, want := len(s.Files), 3; got != want {
+		t.Fatalf("want=%v files, got=%v", want, got)
+	}
+
+	data := []struct {
+		Key  EventKey
+		Want int
+	}{
+		{runeKey('j'), 1},
+		{runeKey('j'), 2},
+		{runeKey('j'), 0},
+		{runeKey('k'), 2},
+		{runeKey('g'), 2},
+		{runeKey('g'), 0},
+		{runeKey('G'), 2},
+	}
+
+	for _, tt := range data {
+		s, _ = Update(s, tt.Key)
+		if got := s.SelectedIdx; got != tt.Want {
+			t.Errorf("after %q: want=%v, got=%v", tt.Key.Rune, tt.Want, got)
+		}
+	}
+}
+
+func TestUpdateQuit(t *testing.T) {
+	s := NewState("/tmp", false, nil, nil)
+
+	_, effects := Update(s, runeKey('q'))
+	if !slices.Contains(effects, Effect(EffectQuit{Path: "/tmp"})) {
+		t.Errorf("want=%+v in effects, got=%+v", EffectQuit{Path: "/tmp"}, effects)
+	}
+}
+
+func TestUpdateCancelPick(t *testing.T) {
+	s := NewState("/tmp", false, nil, nil)
+	s.Pick = PickAny
+
+	for _, key := range []EventKey{runeKey('q'), {Key: tcell.KeyCtrlC}} {
+		_, effects := Update(s, key)
+		if !slices.Contains(effects, Effect(EffectCancel{})) {
+			t.Errorf("key=%+v: want=%+v in effects, got=%+v", key, EffectCancel{}, effects)
+		}
+	}
+}
+
+func TestUpdatePartialListings(t *testing.T) {
+	s := NewState("/tmp/x", false, nil, nil)
+	s, _ = Update(s, EventResize{Width: 80, Height: 24})
+	s, _ = Update(s, runeKey('h'))
+
+	// The cursor lands on the directory that was left once it's been read.
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp", Entries: testEntries(t, "a", "b"), Partial: true})
+	if !s.Loading[PaneCurrent] || s.SelectedIdx != 0 {
+		t.Errorf("want the listing to be loading with the cursor at the top, got loading=%v idx=%v", s.Loading, s.SelectedIdx)
+	}
+
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp", Entries: testEntries(t, "a", "b", "x")})
+	if s.Loading[PaneCurrent] || s.SelectedIdx != 2 {
+		t.Errorf("want the listing to be done with the cursor on x, got loading=%v idx=%v", s.Loading, s.SelectedIdx)
+	}
+
+	// Moving the cursor while the listing is still coming in keeps it on the
+	// entry it was moved to.
+	s = NewState("/tmp/x", false, nil, nil)
+	s, _ = Update(s, EventResize{Width: 80, Height: 24})
+	s, _ = Update(s, runeKey('h'))
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp", Entries: testEntries(t, "b", "c"), Partial: true})
+	s, _ = Update(s, runeKey('j'))
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp", Entries: testEntries(t, "a", "b", "c", "x")})
+	if f, ok := s.SelectedEntry(); !ok || f.Name() != "c" {
+		t.Errorf("want the cursor on c, got %v", s.SelectedIdx)
+	}
+}
+
+func TestUpdateUnreadableDirs(t *testing.T) {
+	s := NewState("/tmp/x", false, nil, nil)
+	s, _ = Update(s, EventResize{Width: 80, Height: 24})
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp/x", Entries: testEntries(t, "a", "b")})
+	s, _ = Update(s, runeKey('j'))
+
+	// A directory that turns out to be unreadable is left again.
+	err := &DirError{Path: "/tmp", Kind: DirErrPermission, Err: fs.ErrPermission}
+	s, _ = Update(s, runeKey('h'))
+	s, effects := Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp", Err: err})
+	if s.Path != "/tmp/x" || s.Err != err {
+		t.Errorf("want to be back in /tmp/x with the error shown, got path=%q err=%v", s.Path, s.Err)
+	}
+	if want := (EffectLoadDir{Pane: PaneCurrent, Path: "/tmp/x"}); !slices.Contains(effects, Effect(want)) {
+		t.Errorf("want %+v in %+v", want, effects)
+	}
+
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp/x", Entries: testEntries(t, "a", "b")})
+	if f, ok := s.SelectedEntry(); !ok || f.Name() != "b" {
+		t.Errorf("want the cursor back on b, got %v", s.SelectedIdx)
+	}
+
+	// Once the parent pane has failed to load, it isn't changed into at all.
+	s, _ = Update(s, EventDirLoaded{Pane: PaneParent, Path: "/tmp", Err: err})
+	s, effects = Update(s, runeKey('h'))
+	if s.Path != "/tmp/x" || s.Err != err || len(effects) != 0 {
+		t.Errorf("want to stay in /tmp/x with the error shown, got path=%q err=%v effects=%+v", s.Path, s.Err, effects)
+	}
+
+	// Without anywhere to go back to, the error is shown in the pane.
+	s = NewState("/tmp", false, nil, nil)
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp", Err: err})
+	if s.Path != "/tmp" || s.LoadErrs[PaneCurrent] != err {
+		t.Errorf("want to stay in /tmp with the error in the pane, got path=%q errs=%v", s.Path, s.LoadErrs)
+	}
+}
+
+func TestUpdateHistory(t *testing.T) {
+	ctrlO := EventKey{Key: tcell.KeyCtrlO}
+	tab := EventKey{Key: tcell.KeyTab}
+
+	s := NewState("/a/b/c", false, nil, nil)
+	s, _ = Update(s, EventResize{Width: 80, Height: 24})
+	s, _ = Update(s, runeKey('h'))
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/a/b", Entries: testEntries(t, "a", "c")})
+	s, _ = Update(s, runeKey('h'))
+
+	data := []struct {
+		Key      EventKey
+		WantPath string
+		WantIdx  int
+	}{
+		{ctrlO, "/a/b", 1},
+		{ctrlO, "/a/b/c", 0},
+		{ctrlO, "/a/b/c", 0},
+		{tab, "/a/b", 1},
+		{tab, "/a", 2},
+		{tab, "/a", 2},
+		{ctrlO, "/a/b", 1},
+	}
+
+	for i, tt := range data {
+		s, _ = Update(s, tt.Key)
+		if s.Path != tt.WantPath || s.HistoryIdx != tt.WantIdx {
+			t.Fatalf("step %v: want path=%q idx=%v, got path=%q idx=%v", i, tt.WantPath, tt.WantIdx, s.Path, s.HistoryIdx)
+		}
+	}
+
+	// The cursor lands on the entry that was selected when /a/b was left.
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/a/b", Entries: testEntries(t, "a", "c")})
+	if f, ok := s.SelectedEntry(); !ok || f.Name() != "c" {
+		t.Errorf("want the cursor on c, got %v", s.SelectedIdx)
+	}
+
+	// Changing directories some other way drops what was gone back from.
+	s, _ = Update(s, runeKey('h'))
+	s, _ = Update(s, ctrlO)
+	s, _ = Update(s, ctrlO)
+	s, _ = Update(s, runeKey('h'))
+	if got := len(s.History); got != 2 || s.HistoryIdx != 1 {
+		t.Errorf("want 2 directories in the history, got %+v at %v", s.History, s.HistoryIdx)
+	}
+}
+
+func TestUpdateIgnoresStaleListings(t *testing.T) {
+	s := NewState("/tmp", false, nil, nil)
+	s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/elsewhere", Entries: testEntries(t, "a")})
+
+	if len(s.Files) != 0 {
+		t.Errorf("want no files, got=%v", len(s.Files))
+	}
+}
+
+func TestUpdateFinderResults(t *testing.T) {
+	batches := [][]walk.Entry{
+		{{Path: "src/main.go"}, {Path: "src", IsDir: true}, {Path: "README.md"}},
+		{{Path: "src/app/main_test.go"}, {Path: "docs/manual.md"}},
+		{{Path: "Makefile"}, {Path: "src/app", IsDir: true}, {Path: "a/m/a/i/n"}},
+	}
+
+	for _, query := range []string{"", "main", "ma", "zzz"} {
+		s := NewState("/tmp", false, nil, nil)
+		s.Mode = ModeFinder
+		s.Finder = FinderState{Root: "/tmp", Query: query, ID: 1}
+
+		for _, batch := range batches {
+			s, _ = Update(s, EventFinderResults{ID: 1, Entries: batch})
+		}
+		// Results of a cancelled walk are dropped.
+		s, _ = Update(s, EventFinderResults{ID: 2, Entries: []walk.Entry{{Path: "main"}}})
+
+		want := s.Finder
+		want.Matches = nil
+		want = want.rank()
+
+		if len(s.Finder.Candidates) != 8 {
+			t.Errorf("query=%q: want 8 candidates, got %v", query, len(s.Finder.Candidates))
+		}
+		if !reflect.DeepEqual(s.Finder.Matches, want.Matches) {
+			t.Errorf("query=%q: want the same matches as ranking everything at once\nwant=%+v\ngot= %+v", query, want.Matches, s.Finder.Matches)
+		}
+	}
+}
+
+func TestUpdateTypesUnboundPendingKeys(t *testing.T) {
+	keymap := DefaultKeymap()
+	if err := keymap.Bind(ModeSearch, "jk", ActionSearchCancel); err != nil {
+		t.Fatal(err)
+	}
+	if err := keymap.Bind(ModeFinder, "jk", ActionFinderCancel); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []struct {
+		Keys          string
+		WantQuery     string
+		WantCancelled bool
+	}{
+		{"jx", "jx", false},
+		{"jjx", "jjx", false},
+		{"ajb", "ajb", false},
+		{"xjk", "", true},
+	}
+
+	for _, tt := range data {
+		s := NewState("/tmp", false, nil, keymap)
+		s, _ = Update(s, EventDirLoaded{Pane: PaneCurrent, Path: "/tmp", Entries: testEntries(t, "ajbjxjjx")})
+		s, _ = Update(s, runeKey('/'))
+		for _, r := range tt.Keys {
+			s, _ = Update(s, runeKey(r))
+		}
+
+		if cancelled := s.Mode == ModeDefault; cancelled != tt.WantCancelled {
+			t.Errorf("search, keys=%q: want cancelled=%v, got mode %v", tt.Keys, tt.WantCancelled, s.Mode)
+		} else if !cancelled && s.SearchEntry != tt.WantQuery {
+			t.Errorf("search, keys=%q: want=%q, got=%q", tt.Keys, tt.WantQuery, s.SearchEntry)
+		}
+
+		s = NewState("/tmp", false, nil, keymap)
+		s.Mode = ModeFinder
+		s.Finder = FinderState{Root: "/tmp", ID: 1}
+		for _, r := range tt.Keys {
+			s, _ = Update(s, runeKey(r))
+		}
+
+		if cancelled := s.Mode == ModeDefault; cancelled != tt.WantCancelled {
+			t.Errorf("finder, keys=%q: want cancelled=%v, got mode %v", tt.Keys, tt.WantCancelled, s.Mode)
+		} else if !cancelled && s.Finder.Query != tt.WantQuery {
+			t.Errorf("finder, keys=%q: want=%q, got=%q", tt.Keys, tt.WantQuery, s.Finder.Query)
+		}
+	}
+
+	// A key bound to an action runs it after the pending keys are typed.
+	s := NewState("/tmp", false, nil, keymap)
+	s.Mode = ModeFinder
+	s.Finder = FinderState{Root: "/tmp", ID: 1}
+	s, _ = Update(s, runeKey('j'))
+	s, _ = Update(s, EventKey{Key: tcell.KeyDown})
+	if s.Finder.Query != "j" || len(s.PendingKeys) != 0 {
+		t.Errorf("want the query to be %q with nothing pending, got %q and %v", "j", s.Finder.Query, s.PendingKeys)
+	}
+}
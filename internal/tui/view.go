@@ -0,0 +1,705 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+
+	"github.com/bnuredini/pathsurfer/internal/gitstatus"
+	"github.com/bnuredini/pathsurfer/internal/preview"
+)
+
+type v4 struct {
+	x1, y1, x2, y2 int
+}
+
+var (
+	StylePathIndicator       = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	StyleActivePathIndicator = tcell.StyleDefault.Foreground(tcell.ColorBlue)
+	StyleReset               = tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
+	StyleError               = tcell.StyleDefault.Foreground(tcell.ColorWhite).Background(tcell.ColorDarkRed)
+	StyleInfo                = tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	StyleSelectedEntry       = tcell.StyleDefault.Background(tcell.ColorDarkBlue).Foreground(tcell.ColorWhite)
+	StylePopupBorder         = tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	StylePopupTitle          = tcell.StyleDefault.Foreground(tcell.ColorYellow).Bold(true)
+	StylePopupText           = tcell.StyleDefault
+	StyleTableHeader         = tcell.StyleDefault.Foreground(tcell.ColorGray).Bold(true)
+	StyleDeadMark            = tcell.StyleDefault.Foreground(tcell.ColorRed)
+	StyleSelectedDeadMark    = StyleSelectedEntry.Foreground(tcell.ColorRed)
+	StyleFinderMatch         = tcell.StyleDefault.Foreground(tcell.ColorYellow).Bold(true)
+	StylePreviewNote         = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	StyleMarkedEntry         = tcell.StyleDefault.Foreground(tcell.ColorYellow).Bold(true)
+	StyleSelectedMarkedEntry = StyleSelectedEntry.Foreground(tcell.ColorYellow).Bold(true)
+	StyleEntryInfo           = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	StyleGitConflicted       = tcell.StyleDefault.Foreground(tcell.ColorRed).Bold(true)
+	StyleGitModified         = tcell.StyleDefault.Foreground(tcell.ColorYellow)
+	StyleGitStaged           = tcell.StyleDefault.Foreground(tcell.ColorGreen)
+	StyleGitUntracked        = tcell.StyleDefault.Foreground(tcell.ColorPurple)
+	StyleGitIgnored          = tcell.StyleDefault.Foreground(tcell.ColorGray)
+
+	// StyleSyntax holds the styles of highlighted source code in previews.
+	// Kinds that are missing are drawn with the default style. NewProgram
+	// replaces them with the ones from the config file, see NewSyntaxStyles.
+	StyleSyntax = DefaultSyntaxStyles()
+)
+
+// View draws s on screen. It doesn't call screen.Show so that the caller can
+// decide when the changes become visible.
+func View(s State, screen tcell.Screen) {
+	switch s.Mode {
+	case ModeMarkManager:
+		drawMarkManager(s, screen)
+	case ModeFinder:
+		drawFinder(s, screen)
+	default:
+		drawFileList(s, screen)
+	}
+
+	if s.Err != nil {
+		drawErrorLine(screen, s.Err)
+	} else {
+		drawInfoLine(s, screen)
+	}
+
+	switch s.Mode {
+	case ModeListeningForMark:
+		drawMarkHintSection(s, screen)
+	case ModeHistory:
+		drawHistoryPopup(s, screen)
+	}
+}
+
+func drawFileList(s State, screen tcell.Screen) {
+	screen.Clear()
+
+	w, h := screen.Size()
+	secondaryPaneWidth := w / 6
+
+	leftPaneDimensions := v4{
+		x1: 0,
+		y1: 2,
+		x2: secondaryPaneWidth,
+		y2: h - 1,
+	}
+	mainPaneDimensions := v4{
+		x1: leftPaneDimensions.x2 + 2,
+		y1: 2,
+		x2: leftPaneDimensions.x2 + (3 * secondaryPaneWidth),
+		y2: h - 1,
+	}
+	rightPaneDimensions := v4{
+		x1: mainPaneDimensions.x2 + 2,
+		y1: 2,
+		x2: mainPaneDimensions.x2 + secondaryPaneWidth,
+		y2: h - 1,
+	}
+
+	sep1X := leftPaneDimensions.x2 + 1
+	sep2X := mainPaneDimensions.x2 + 1
+
+	for i := range h {
+		screen.SetContent(sep1X, i, '|', nil, tcell.StyleDefault)
+		screen.SetContent(sep2X, i, '|', nil, tcell.StyleDefault)
+	}
+
+	dimensions := v4{x1: mainPaneDimensions.x1, y1: 0, x2: w, y2: 0}
+	if s.Mode == ModeSearch {
+		text := fmt.Sprintf("%s: %s/%s", s.SearchBarPrefix, s.Path, s.SearchEntry)
+		drawText(screen, dimensions, StyleActivePathIndicator, text)
+		screen.ShowCursor(dimensions.x1+len([]rune(text)), dimensions.y1)
+		screen.SetCursorStyle(tcell.CursorStyleBlinkingBlock)
+	} else {
+		text := fmt.Sprintf("%s: %s", s.SearchBarPrefix, s.Path)
+		if s.Prompt != "" {
+			text = fmt.Sprintf("%s %s", s.Prompt, s.Path)
+		}
+		if summary := gitSummary(s.Git); summary != "" {
+			text += "  " + summary
+		}
+		if s.Loading[PaneCurrent] {
+			text += fmt.Sprintf("  (loading… %d entries)", len(s.Entries))
+		}
+		if len(s.Selection) > 0 {
+			text += fmt.Sprintf("  (%d selected)", len(s.Selection))
+		}
+		if order := s.sortOrderFor(s.Path); order != s.DefaultSort {
+			text += fmt.Sprintf("  (sorted by %s)", order)
+		}
+		drawText(screen, dimensions, StylePathIndicator, text)
+		screen.HideCursor()
+	}
+
+	if s.ShowInfo {
+		infoDimensions := v4{x1: mainPaneDimensions.x1, y1: 1, x2: w, y2: 1}
+		drawLine(screen, infoDimensions.x1, infoDimensions.x2, infoDimensions.y1, StyleEntryInfo, entryInfo(s))
+	}
+
+	parentSelectedIdx := slices.IndexFunc(s.ParentFiles, func(f fs.DirEntry) bool {
+		return f.Name() == filepath.Base(s.Path)
+	})
+	parentScrollOffset := calculateScrollOffsetForHeight(
+		parentSelectedIdx,
+		0,
+		s.listHeight(),
+		len(s.ParentFiles),
+	)
+
+	marked := s.markedPaths()
+	drawPane(screen, s.ParentFiles, leftPaneDimensions, parentSelectedIdx, parentScrollOffset, s.ParentPath, s.Git, marked)
+	drawPane(screen, s.Files, mainPaneDimensions, s.SelectedIdx, s.ScrollOffset, s.Path, s.Git, marked)
+	if s.PreviewPath != "" {
+		drawPreview(s, screen, rightPaneDimensions)
+	} else {
+		drawPane(screen, s.ChildFiles, rightPaneDimensions, 0, 0, s.ChildPath, s.Git, marked)
+	}
+
+	// Panes whose listings are still being read show a note until the first
+	// entries come in. Panes whose listings couldn't be read say why.
+	panes := []struct {
+		pane       Pane
+		files      []fs.DirEntry
+		dimensions v4
+	}{
+		{PaneParent, s.ParentFiles, leftPaneDimensions},
+		{PaneCurrent, s.Files, mainPaneDimensions},
+		{PaneChild, s.ChildFiles, rightPaneDimensions},
+	}
+	for _, p := range panes {
+		if len(p.files) > 0 {
+			continue
+		}
+
+		d := p.dimensions
+		if err := s.LoadErrs[p.pane]; err != nil {
+			drawLine(screen, d.x1, d.x2, d.y1, StyleError, dirErrReason(err))
+		} else if s.Loading[p.pane] {
+			drawLine(screen, d.x1, d.x2, d.y1, StylePreviewNote, "loading…")
+		}
+	}
+}
+
+// drawPreview draws the beginning of the selected file. Lines that don't fit
+// are cut off instead of being wrapped.
+func drawPreview(s State, screen tcell.Screen, dimensions v4) {
+	if s.PreviewErr != nil {
+		drawText(screen, dimensions, StylePreviewNote, s.PreviewErr.Error())
+		return
+	}
+
+	y := dimensions.y1
+	switch s.Preview.Kind {
+	case preview.KindBinary:
+		drawLine(screen, dimensions.x1, dimensions.x2, y, StylePreviewNote, "binary, "+preview.FormatSize(s.Preview.Size))
+		y++
+	case preview.KindSpecial:
+		drawLine(screen, dimensions.x1, dimensions.x2, y, StylePreviewNote, "not a regular file")
+		return
+	}
+
+	for i, line := range s.Preview.Lines {
+		if y >= dimensions.y2 {
+			break
+		}
+
+		if i < len(s.PreviewTokens) {
+			x := dimensions.x1
+			for _, token := range s.PreviewTokens[i] {
+				x = drawLine(screen, x, dimensions.x2, y, StyleSyntax[token.Kind], token.Text)
+			}
+		} else {
+			drawLine(screen, dimensions.x1, dimensions.x2, y, tcell.StyleDefault, line)
+		}
+		y++
+	}
+}
+
+// drawPane draws the entries of dir. Entries that git knows something about get
+// a marker in the last column and entries whose paths are in marked get one in
+// the column before it.
+func drawPane(
+	screen tcell.Screen,
+	entries []fs.DirEntry,
+	dimensions v4,
+	selectedMarker int,
+	scrollMarker int,
+	dir string,
+	git gitstatus.Status,
+	marked map[string]bool,
+) {
+	heightUsableForFiles := dimensions.y2 - dimensions.y1
+
+	for i := range heightUsableForFiles {
+		fileIdx := scrollMarker + i
+		if fileIdx >= len(entries) {
+			break
+		}
+
+		prefix := "  "
+		style := tcell.StyleDefault
+		file := entries[fileIdx]
+
+		if file.IsDir() {
+			prefix = "📁 "
+			style = style.Foreground(tcell.ColorGreen)
+		}
+		path := filepath.Join(dir, file.Name())
+		isMarked := marked[path]
+
+		if isMarked {
+			style = StyleMarkedEntry
+		}
+		if fileIdx == selectedMarker {
+			style = StyleSelectedEntry
+			if isMarked {
+				style = StyleSelectedMarkedEntry
+			}
+		}
+
+		drawText(
+			screen,
+			v4{x1: dimensions.x1, y1: dimensions.y1 + i, x2: dimensions.x2, y2: dimensions.y1 + i},
+			style,
+			fmt.Sprintf("%s%s", prefix, file.Name()),
+		)
+
+		if isMarked {
+			screen.SetContent(dimensions.x2-2, dimensions.y1+i, '*', nil, style)
+		}
+
+		status := git.For(path, file.IsDir())
+		if marker, markerStyle, ok := gitMarker(status); ok {
+			if fileIdx == selectedMarker {
+				markerStyle = markerStyle.Background(tcell.ColorDarkBlue)
+			}
+			screen.SetContent(dimensions.x2-1, dimensions.y1+i, marker, nil, markerStyle)
+		}
+	}
+}
+
+// entryInfo describes the selected entry in a single line, e.g.
+// "drwxr-xr-x  alice:staff  2024-03-01 14:22  1.2 MiB in 40 files, 3 dirs".
+func entryInfo(s State) string {
+	if s.InfoPath == "" {
+		return ""
+	}
+	if s.InfoErr != nil {
+		return s.InfoErr.Error()
+	}
+	if s.Info.ModTime.IsZero() {
+		// The details haven't arrived yet.
+		return ""
+	}
+
+	parts := []string{s.Info.Mode.String()}
+	if s.Info.Owner != "" {
+		parts = append(parts, s.Info.Owner+":"+s.Info.Group)
+	}
+	parts = append(parts, s.Info.ModTime.Format("2006-01-02 15:04"))
+
+	switch {
+	case s.Info.LinkTarget != "":
+		parts = append(parts, "-> "+s.Info.LinkTarget)
+
+	case s.Info.Mode.IsDir():
+		usage := fmt.Sprintf(
+			"%s in %d files, %d dirs",
+			preview.FormatSize(s.Usage.Size),
+			s.Usage.Files,
+			s.Usage.Dirs,
+		)
+		if !s.UsageDone {
+			usage += " (counting…)"
+		}
+		parts = append(parts, usage)
+
+	default:
+		parts = append(parts, preview.FormatSize(s.Info.Size))
+	}
+
+	return strings.Join(parts, "  ")
+}
+
+// gitMarker returns the marker drawn next to an entry with the given status.
+// Only the most important part of the status is shown.
+func gitMarker(status gitstatus.FileStatus) (rune, tcell.Style, bool) {
+	switch {
+	case status&gitstatus.Conflicted != 0:
+		return 'U', StyleGitConflicted, true
+	case status&gitstatus.Modified != 0:
+		return 'M', StyleGitModified, true
+	case status&gitstatus.Staged != 0:
+		return '+', StyleGitStaged, true
+	case status&gitstatus.Untracked != 0:
+		return '?', StyleGitUntracked, true
+	case status&gitstatus.Ignored != 0:
+		return '!', StyleGitIgnored, true
+	}
+
+	return 0, tcell.StyleDefault, false
+}
+
+// gitSummary describes the branch of the work tree, e.g. "[main ↑1 ↓2]". It's
+// empty outside of work trees.
+func gitSummary(git gitstatus.Status) string {
+	if git.Root == "" {
+		return ""
+	}
+
+	head := git.Branch
+	if head == "" {
+		head = "@" + git.Commit[:min(len(git.Commit), 7)]
+	}
+	if git.Ahead > 0 {
+		head += fmt.Sprintf(" ↑%d", git.Ahead)
+	}
+	if git.Behind > 0 {
+		head += fmt.Sprintf(" ↓%d", git.Behind)
+	}
+
+	return "[" + head + "]"
+}
+
+// drawMarkManager draws every mark in a table that takes up the whole screen
+// apart from the bottom line. Marks whose directories are gone are drawn in
+// red.
+func drawMarkManager(s State, screen tcell.Screen) {
+	screen.Clear()
+	screen.HideCursor()
+
+	w, h := screen.Size()
+	keys := s.markKeys()
+
+	drawText(screen, v4{0, 0, w, 0}, StyleActivePathIndicator, fmt.Sprintf("marks (%d)", len(keys)))
+
+	if len(keys) == 0 {
+		drawText(screen, v4{0, 2, w, 2}, StylePopupText, "No marks have been set yet. Press m in the file list to set one.")
+		return
+	}
+
+	const (
+		statusX = 5
+		pathX   = 15
+	)
+
+	drawText(screen, v4{0, 2, statusX, 2}, StyleTableHeader, "KEY")
+	drawText(screen, v4{statusX, 2, pathX, 2}, StyleTableHeader, "STATUS")
+	drawText(screen, v4{pathX, 2, w, 2}, StyleTableHeader, "PATH")
+
+	// One row less than in the file list is available because of the table
+	// header.
+	listHeight := max(h-4, 1)
+	scrollOffset := calculateScrollOffsetForHeight(s.MarkIdx, 0, listHeight, len(keys))
+
+	for i := range listHeight {
+		markIdx := scrollOffset + i
+		if markIdx >= len(keys) {
+			break
+		}
+
+		key := keys[markIdx]
+		missing := s.MissingMarks[key]
+
+		style := tcell.StyleDefault
+		switch {
+		case markIdx == s.MarkIdx && missing:
+			style = StyleSelectedDeadMark
+		case markIdx == s.MarkIdx:
+			style = StyleSelectedEntry
+		case missing:
+			style = StyleDeadMark
+		}
+
+		status := "ok"
+		if missing {
+			status = "missing"
+		}
+
+		y := 3 + i
+		for x := range w {
+			screen.SetContent(x, y, ' ', nil, style)
+		}
+
+		drawText(screen, v4{0, y, statusX, y}, style, string(key))
+		drawText(screen, v4{statusX, y, pathX, y}, style, status)
+		drawText(screen, v4{pathX, y, w, y}, style, s.Marks[key])
+	}
+}
+
+// drawFinder draws the finder's query and its matches over the whole screen
+// apart from the bottom line.
+func drawFinder(s State, screen tcell.Screen) {
+	screen.Clear()
+
+	w, h := screen.Size()
+	f := s.Finder
+
+	text := fmt.Sprintf("find: %s/%s", f.Root, f.Query)
+	drawText(screen, v4{0, 0, w, 0}, StyleActivePathIndicator, text)
+	screen.ShowCursor(len([]rune(text)), 0)
+	screen.SetCursorStyle(tcell.CursorStyleBlinkingBlock)
+
+	status := fmt.Sprintf("%d/%d", len(f.Matches), len(f.Candidates))
+	if !f.Done {
+		status += " (searching…)"
+	}
+	drawText(screen, v4{0, 1, w, 1}, StylePathIndicator, status)
+
+	listHeight := max(h-3, 1)
+	scrollOffset := calculateScrollOffsetForHeight(f.SelectedIdx, 0, listHeight, len(f.Matches))
+
+	for i := range listHeight {
+		matchIdx := scrollOffset + i
+		if matchIdx >= len(f.Matches) {
+			break
+		}
+
+		match := f.Matches[matchIdx]
+		y := 2 + i
+
+		style := tcell.StyleDefault
+		if match.Entry.IsDir {
+			style = style.Foreground(tcell.ColorGreen)
+		}
+		matchStyle := StyleFinderMatch
+		if matchIdx == f.SelectedIdx {
+			style = StyleSelectedEntry
+			matchStyle = StyleSelectedEntry.Bold(true)
+			for x := range w {
+				screen.SetContent(x, y, ' ', nil, style)
+			}
+		}
+
+		prefix := "  "
+		if match.Entry.IsDir {
+			prefix = "📁 "
+		}
+		drawText(screen, v4{0, y, w, y}, style, prefix)
+
+		x := len([]rune(prefix))
+		matched := 0
+		for byteIdx, r := range match.Entry.Path {
+			if x >= w {
+				break
+			}
+
+			runeStyle := style
+			if matched < len(match.Indexes) && match.Indexes[matched] == byteIdx {
+				runeStyle = matchStyle
+				matched++
+			}
+
+			screen.SetContent(x, y, r, nil, runeStyle)
+			x++
+		}
+	}
+}
+
+func drawInfoLine(s State, screen tcell.Screen) {
+	text := "(j/k: up/down) (l: enter) (h: parent) (/: search) (. hidden) (q: quit)"
+	if s.Pick != PickNone {
+		text = "(j/k: up/down) (l: enter) (h: parent) (/: search) (space: select) (enter: pick) (q: cancel)"
+	}
+	if _, _, ok := s.visualRange(); ok && s.Mode == ModeDefault {
+		text = "select range: move to extend it (V: add to the selection) (u: cancel)"
+	}
+
+	switch s.Mode {
+	case ModeRecordingMark:
+		text = "set mark: press the key to use for the current directory (ESC: cancel)"
+	case ModeListeningForMark:
+		text = "jump to mark: press the key of a mark (ESC: cancel)"
+	case ModeMarkManager:
+		text = "(j/k: up/down) (l: jump) (d: delete) (r: change key) (p: point here) (q: close)"
+		if s.RenamingMark {
+			text = "change key: press the new key for the selected mark (ESC: cancel)"
+		}
+	case ModeFinder:
+		text = "(C-n/C-p: down/up) (enter: go to the match) (ESC: cancel)"
+	case ModeHistory:
+		text = "(j/k: older/newer) (l: go there) (q: close)"
+	}
+
+	w, h := screen.Size()
+	dimensions := v4{0, h - 1, w, h - 1}
+	drawText(
+		screen,
+		dimensions,
+		StyleInfo,
+		text,
+	)
+}
+
+// dirErrReason returns the short description of err if it's a DirError.
+func dirErrReason(err error) string {
+	var dirErr *DirError
+	if errors.As(err, &dirErr) {
+		return dirErr.Reason()
+	}
+
+	return err.Error()
+}
+
+func drawErrorLine(screen tcell.Screen, err error) {
+	w, h := screen.Size()
+	dimensions := v4{0, h - 1, w, h - 1}
+	drawText(
+		screen,
+		dimensions,
+		StyleError,
+		err.Error(),
+	)
+}
+
+func drawMarkHintSection(s State, screen tcell.Screen) {
+	lines := []string{}
+	for _, key := range slices.Sorted(maps.Keys(s.Marks)) {
+		lines = append(lines, fmt.Sprintf("%c  %s", key, s.Marks[key]))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "No marks have been set yet. Press m to set one.")
+	}
+
+	drawPopup(screen, "marks", lines, -1)
+}
+
+// drawHistoryPopup lists the history, newest first. The current directory is
+// marked with an asterisk.
+func drawHistoryPopup(s State, screen tcell.Screen) {
+	lines := make([]string, 0, len(s.History))
+	for i := len(s.History) - 1; i >= 0; i-- {
+		marker := " "
+		if i == s.HistoryIdx {
+			marker = "*"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", marker, s.History[i].Path))
+	}
+
+	drawPopup(screen, "history", lines, len(s.History)-1-s.HistoryMenuIdx)
+}
+
+// drawPopup draws a bordered box with the given lines above the bottom line,
+// centered horizontally. If selected is a valid index, that line is
+// highlighted and the lines scroll to keep it visible. Otherwise, lines that
+// don't fit are cut off.
+func drawPopup(screen tcell.Screen, title string, lines []string, selected int) {
+	w, h := screen.Size()
+
+	contentWidth := len([]rune(title)) + 2
+	for _, line := range lines {
+		contentWidth = max(contentWidth, len([]rune(line)))
+	}
+
+	boxWidth := min(contentWidth+4, w)
+	boxHeight := min(len(lines)+2, h-1)
+	if boxWidth < 5 || boxHeight < 3 {
+		return
+	}
+
+	box := v4{
+		x1: (w - boxWidth) / 2,
+		y1: (h - 1) - boxHeight,
+		x2: (w-boxWidth)/2 + boxWidth - 1,
+		y2: (h - 1) - 1,
+	}
+
+	for y := box.y1; y <= box.y2; y++ {
+		for x := box.x1; x <= box.x2; x++ {
+			r := ' '
+			switch {
+			case x == box.x1 && y == box.y1:
+				r = tcell.RuneULCorner
+			case x == box.x2 && y == box.y1:
+				r = tcell.RuneURCorner
+			case x == box.x1 && y == box.y2:
+				r = tcell.RuneLLCorner
+			case x == box.x2 && y == box.y2:
+				r = tcell.RuneLRCorner
+			case y == box.y1 || y == box.y2:
+				r = tcell.RuneHLine
+			case x == box.x1 || x == box.x2:
+				r = tcell.RuneVLine
+			}
+
+			screen.SetContent(x, y, r, nil, StylePopupBorder)
+		}
+	}
+
+	drawText(screen, v4{box.x1 + 2, box.y1, box.x2 - 1, box.y1}, StylePopupTitle, " "+title+" ")
+
+	visibleLines := box.y2 - box.y1 - 1
+	offset := 0
+	if selected >= 0 && selected < len(lines) {
+		offset = calculateScrollOffsetForHeight(selected, 0, visibleLines, len(lines))
+		lines = lines[offset:min(offset+visibleLines, len(lines))]
+	} else if len(lines) > visibleLines {
+		hidden := len(lines) - visibleLines + 1
+		lines = append(lines[:visibleLines-1:visibleLines-1], fmt.Sprintf("… and %d more", hidden))
+	}
+
+	for i, line := range lines {
+		y := box.y1 + 1 + i
+		style := StylePopupText
+		if offset+i == selected {
+			style = StyleSelectedEntry
+			for x := box.x1 + 1; x < box.x2; x++ {
+				screen.SetContent(x, y, ' ', nil, style)
+			}
+		}
+
+		drawText(screen, v4{box.x1 + 2, y, box.x2 - 1, y}, style, line)
+	}
+}
+
+// drawLine draws text on row y from x1 up to, but not including, x2 and cuts
+// off whatever doesn't fit. Wide characters take up two cells. It returns the
+// column after the text.
+func drawLine(screen tcell.Screen, x1, x2, y int, style tcell.Style, text string) int {
+	x, lastX := x1, -1
+
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if w == 0 {
+			// Combining characters go into the cell before them.
+			if lastX != -1 {
+				mainc, combc, _, _ := screen.GetContent(lastX, y)
+				screen.SetContent(lastX, y, mainc, append(combc, r), style)
+			}
+			continue
+		}
+
+		if x+w > x2 {
+			break
+		}
+
+		screen.SetContent(x, y, r, nil, style)
+		lastX = x
+		x += w
+	}
+
+	return x
+}
+
+func drawText(screen tcell.Screen, dimensions v4, style tcell.Style, text string) {
+	currCol := dimensions.x1
+	currRow := dimensions.y1
+
+	for _, r := range text {
+		screen.SetContent(currCol, currRow, r, nil, style)
+		currCol++
+		if currCol >= dimensions.x2 {
+			currRow++
+			currCol = dimensions.x1
+		}
+		if currRow > dimensions.y2 {
+			break
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package walk lists everything below a directory using several goroutines at
+// once and reports what it finds in batches while it's still going.
+package walk
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is something found below the root. Path is relative to the root and
+// always uses forward slashes.
+type Entry struct {
+	Path  string
+	IsDir bool
+}
+
+type Options struct {
+	// MaxDepth limits how deep the walk goes. Entries directly in the root
+	// are at depth 1. Zero or less means that there's no limit.
+	MaxDepth int
+
+	// Exclude holds glob patterns, see path.Match. Entries whose name or
+	// relative path matches one of them are skipped along with everything
+	// below them.
+	Exclude []string
+
+	ShowHidden bool
+
+	// Ignored, if set, is called with the absolute path of every entry.
+	// Entries it returns true for are skipped along with everything below
+	// them. It's called from several goroutines at once.
+	Ignored func(path string, isDir bool) bool
+
+	// Workers is the number of directories read at the same time. It
+	// defaults to 8.
+	Workers int
+}
+
+const (
+	batchSize     = 512
+	batchInterval = 50 * time.Millisecond
+)
+
+// Walk lists everything below root and calls emit with what it has found so
+// far every now and then. Calls to emit never overlap. Directories that can't
+// be read are skipped, except for the root itself. Symbolic links aren't
+// followed.
+//
+// Walk returns once everything has been found or ctx is cancelled, in which
+// case ctx.Err() is returned.
+func Walk(ctx context.Context, root string, opts Options, emit func([]Entry)) error {
+	if _, err := os.ReadDir(root); err != nil {
+		return err
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = 8
+	}
+
+	w := &walker{
+		ctx:   ctx,
+		root:  root,
+		opts:  opts,
+		sem:   make(chan struct{}, opts.Workers),
+		found: make(chan Entry, batchSize),
+	}
+
+	w.wg.Add(1)
+	go w.walkDir("", 1)
+
+	go func() {
+		w.wg.Wait()
+		close(w.found)
+	}()
+
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	batch := []Entry{}
+	flush := func() {
+		if len(batch) > 0 {
+			emit(batch)
+			batch = []Entry{}
+		}
+	}
+
+	for {
+		select {
+		case entry, ok := <-w.found:
+			if !ok {
+				flush()
+				return ctx.Err()
+			}
+
+			batch = append(batch, entry)
+			if len(batch) >= batchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+
+		case <-ctx.Done():
+			// The goroutines that are still running notice the cancellation
+			// on their own and close w.found once they're done.
+			return ctx.Err()
+		}
+	}
+}
+
+type walker struct {
+	ctx   context.Context
+	root  string
+	opts  Options
+	wg    sync.WaitGroup
+	sem   chan struct{}
+	found chan Entry
+}
+
+func (w *walker) walkDir(rel string, depth int) {
+	defer w.wg.Done()
+
+	select {
+	case w.sem <- struct{}{}:
+	case <-w.ctx.Done():
+		return
+	}
+	entries, err := os.ReadDir(filepath.Join(w.root, filepath.FromSlash(rel)))
+	<-w.sem
+
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		entryRel := path.Join(rel, name)
+
+		if (!w.opts.ShowHidden && strings.HasPrefix(name, ".")) || w.excluded(name, entryRel) {
+			continue
+		}
+		if w.opts.Ignored != nil && w.opts.Ignored(filepath.Join(w.root, filepath.FromSlash(entryRel)), entry.IsDir()) {
+			continue
+		}
+
+		select {
+		case w.found <- Entry{Path: entryRel, IsDir: entry.IsDir()}:
+		case <-w.ctx.Done():
+			return
+		}
+
+		if entry.IsDir() && (w.opts.MaxDepth <= 0 || depth < w.opts.MaxDepth) {
+			w.wg.Add(1)
+			go w.walkDir(entryRel, depth+1)
+		}
+	}
+}
+
+func (w *walker) excluded(name, rel string) bool {
+	for _, pattern := range w.opts.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func makeTree(t *testing.T, paths ...string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, p := range paths {
+		full := filepath.Join(root, filepath.FromSlash(p))
+
+		if strings.HasSuffix(p, "/") {
+			if err := os.MkdirAll(full, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func walkAll(t *testing.T, root string, opts Options) []string {
+	t.Helper()
+
+	result := []string{}
+	err := Walk(context.Background(), root, opts, func(batch []Entry) {
+		for _, entry := range batch {
+			p := entry.Path
+			if entry.IsDir {
+				p += "/"
+			}
+			result = append(result, p)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(result)
+	return result
+}
+
+func TestWalk(t *testing.T) {
+	root := makeTree(
+		t,
+		"a/b/c/deep.txt",
+		"a/one.txt",
+		"top.txt",
+		".hidden/secret.txt",
+		"node_modules/pkg/index.js",
+		"src/x.log",
+		"src/x.go",
+	)
+
+	data := []struct {
+		Opts Options
+		Want []string
+	}{
+		{
+			Options{},
+			[]string{"a/", "a/b/", "a/b/c/", "a/b/c/deep.txt", "a/one.txt", "node_modules/", "node_modules/pkg/", "node_modules/pkg/index.js", "src/", "src/x.go", "src/x.log", "top.txt"},
+		},
+		{
+			Options{MaxDepth: 2, Exclude: []string{"node_modules", "*.log"}},
+			[]string{"a/", "a/b/", "a/one.txt", "src/", "src/x.go", "top.txt"},
+		},
+		{
+			Options{MaxDepth: 1, ShowHidden: true, Exclude: []string{"a/*"}},
+			[]string{".hidden/", "a/", "node_modules/", "src/", "top.txt"},
+		},
+		{
+			Options{Ignored: func(path string, isDir bool) bool {
+				return (isDir && filepath.Base(path) == "b") || filepath.Ext(path) == ".go"
+			}},
+			[]string{"a/", "a/one.txt", "node_modules/", "node_modules/pkg/", "node_modules/pkg/index.js", "src/", "src/x.log", "top.txt"},
+		},
+	}
+
+	for _, tt := range data {
+		if got := walkAll(t, root, tt.Opts); !reflect.DeepEqual(got, tt.Want) {
+			t.Errorf("want=%q, got=%q", tt.Want, got)
+		}
+	}
+}
+
+func TestWalkErrors(t *testing.T) {
+	err := Walk(context.Background(), filepath.Join(t.TempDir(), "missing"), Options{}, func([]Entry) {})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want=%v, got=%v", os.ErrNotExist, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = Walk(ctx, makeTree(t, "a/b/c.txt"), Options{}, func([]Entry) {})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want=%v, got=%v", context.Canceled, err)
+	}
+}
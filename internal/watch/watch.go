@@ -0,0 +1,77 @@
+// Package watch reports when the listings of directories change.
+package watch
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Delay is how long changes are collected after the first one before they're
+// reported together. A build writing lots of files is reported every Delay
+// rather than once per file.
+const Delay = 100 * time.Millisecond
+
+// Watcher watches a set of directories. It calls onChange from a goroutine of
+// its own with the directories that changed, sorted. Only changes to what's in
+// the directories count: entries being created, removed, renamed, written to
+// or having their permissions changed.
+type Watcher struct {
+	onChange func(dirs []string)
+
+	mu      sync.Mutex
+	pending map[string]bool
+	timer   *time.Timer
+	closed  bool
+
+	backend
+}
+
+// changed schedules dir to be reported.
+func (w *Watcher) changed(dirs ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed || len(dirs) == 0 {
+		return
+	}
+
+	for _, dir := range dirs {
+		w.pending[dir] = true
+	}
+	if w.timer == nil {
+		w.timer = time.AfterFunc(Delay, w.flush)
+	}
+}
+
+func (w *Watcher) flush() {
+	w.mu.Lock()
+	dirs := slices.Sorted(maps.Keys(w.pending))
+	clear(w.pending)
+	w.timer = nil
+	closed := w.closed
+	w.mu.Unlock()
+
+	if !closed && len(dirs) > 0 {
+		w.onChange(dirs)
+	}
+}
+
+// markClosed stops further reports and reports whether the watcher was open.
+func (w *Watcher) markClosed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return false
+	}
+
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+
+	return true
+}
@@ -0,0 +1,161 @@
+//go:build linux
+
+package watch
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_CLOSE_WRITE | unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR
+
+type backend struct {
+	fd   int
+	file *os.File
+	done chan struct{}
+
+	// Directories that are the same inode, e.g. through symbolic links, share
+	// a watch descriptor.
+	dirs    map[string]int
+	watches map[int][]string
+}
+
+// New starts a watcher that isn't watching anything yet.
+func New(onChange func(dirs []string)) (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &Watcher{
+		onChange: onChange,
+		pending:  make(map[string]bool),
+		backend: backend{
+			fd: fd,
+			// A non-blocking descriptor goes through the runtime's poller,
+			// which lets Close interrupt a pending read.
+			file:    os.NewFile(uintptr(fd), "inotify"),
+			done:    make(chan struct{}),
+			dirs:    make(map[string]int),
+			watches: make(map[int][]string),
+		},
+	}
+
+	go w.read()
+
+	return w, nil
+}
+
+// Set replaces the watched directories with dirs. Directories that can't be
+// watched are skipped and reported in the returned error.
+func (w *Watcher) Set(dirs []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	for dir, wd := range w.dirs {
+		if !slices.Contains(dirs, dir) {
+			w.unwatch(dir, wd)
+		}
+	}
+
+	var errs []error
+	for _, dir := range dirs {
+		if _, ok := w.dirs[dir]; ok || dir == "" {
+			continue
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, dir, watchMask)
+		if err != nil {
+			errs = append(errs, &fs.PathError{Op: "watch", Path: dir, Err: err})
+			continue
+		}
+
+		w.dirs[dir] = wd
+		w.watches[wd] = append(w.watches[wd], dir)
+	}
+
+	return errors.Join(errs...)
+}
+
+// unwatch stops watching dir. The caller must hold w.mu.
+func (w *Watcher) unwatch(dir string, wd int) {
+	delete(w.dirs, dir)
+
+	rest := slices.DeleteFunc(w.watches[wd], func(d string) bool { return d == dir })
+	if len(rest) > 0 {
+		w.watches[wd] = rest
+		return
+	}
+
+	delete(w.watches, wd)
+	_, _ = unix.InotifyRmWatch(w.fd, uint32(wd))
+}
+
+// Close stops watching. Nothing is reported once it returns.
+func (w *Watcher) Close() error {
+	if !w.markClosed() {
+		return nil
+	}
+
+	err := w.file.Close()
+	<-w.done
+
+	return err
+}
+
+func (w *Watcher) read() {
+	defer close(w.done)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			wd := int(int32(binary.NativeEndian.Uint32(buf[offset:])))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := binary.NativeEndian.Uint32(buf[offset+12:])
+			offset += unix.SizeofInotifyEvent + int(nameLen)
+
+			w.changed(w.dirsFor(wd, mask)...)
+		}
+	}
+}
+
+// dirsFor returns the directories that an event for wd concerns.
+func (w *Watcher) dirsFor(wd int, mask uint32) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		// Events were dropped, so anything might have changed.
+		return slices.Collect(maps.Keys(w.dirs))
+	}
+
+	dirs := w.watches[wd]
+	if mask&unix.IN_IGNORED != 0 {
+		// The directory is gone, which has been reported already, or Set
+		// stopped watching it.
+		for _, dir := range dirs {
+			delete(w.dirs, dir)
+		}
+		delete(w.watches, wd)
+
+		return nil
+	}
+
+	return slices.Clone(dirs)
+}
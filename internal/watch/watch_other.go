@@ -0,0 +1,24 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+// Directories can only be watched on Linux for now.
+
+type backend struct{}
+
+// New returns errors.ErrUnsupported.
+func New(onChange func(dirs []string)) (*Watcher, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// Set does nothing.
+func (w *Watcher) Set(dirs []string) error {
+	return nil
+}
+
+// Close does nothing.
+func (w *Watcher) Close() error {
+	return nil
+}
@@ -0,0 +1,106 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newWatcher(t *testing.T) (*Watcher, chan []string) {
+	t.Helper()
+
+	reports := make(chan []string, 16)
+	w, err := New(func(dirs []string) { reports <- dirs })
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("watching isn't supported on this platform")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	return w, reports
+}
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForReports fails the test unless the directories reported from now on
+// add up to want.
+func waitForReports(t *testing.T, reports chan []string, want []string) {
+	t.Helper()
+
+	got := []string{}
+	timeout := time.After(5 * time.Second)
+
+	for !slices.Equal(got, want) {
+		select {
+		case dirs := <-reports:
+			got = slices.Compact(slices.Sorted(slices.Values(append(got, dirs...))))
+		case <-timeout:
+			t.Fatalf("want=%q reported, got=%q", want, got)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	w, reports := newWatcher(t)
+	one, two := t.TempDir(), t.TempDir()
+
+	if err := w.Set([]string{one, two}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 10 {
+		touch(t, filepath.Join(one, string(rune('a'+i))))
+	}
+	touch(t, filepath.Join(two, "x"))
+
+	waitForReports(t, reports, []string{one, two})
+
+	// Directories that aren't watched anymore aren't reported.
+	if err := w.Set([]string{two}); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, filepath.Join(one, "ignored"))
+	if err := os.Remove(filepath.Join(two, "x")); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForReports(t, reports, []string{two})
+
+	if err := w.Set([]string{filepath.Join(one, "missing")}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("want=%v, got=%v", os.ErrNotExist, err)
+	}
+}
+
+func TestWatcherClose(t *testing.T) {
+	w, reports := newWatcher(t)
+	dir := t.TempDir()
+
+	if err := w.Set([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, filepath.Join(dir, "a"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-reports:
+		t.Errorf("want nothing to be reported after closing, got %q", got)
+	case <-time.After(2 * Delay):
+	}
+
+	if err := w.Set([]string{dir}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("want=%v, got=%v", os.ErrClosed, err)
+	}
+}